@@ -0,0 +1,269 @@
+/**
+ * Created by GoLand.
+ * @author: clyde
+ * @date: 2021/11/24 下午2:10
+ * @note: with aggregation the matches of a rule, per aggregation_key value, are held until the aggregation period
+ * ends, or max_aggregation of them are held, then sent as a single alert. Held matches are kept in the writeback
+ * index as an alert not sent yet, so they are sent by sendPendingAlerts after a restart
+ */
+
+package elastalert
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/olivere/elastic/v7"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// AlertAggregation the aggregation settings of a rule.
+type AlertAggregation struct {
+	// Window the aggregation period, when there is no schedule
+	Window time.Duration
+
+	Key                string
+	SummaryTableFields []string
+
+	schedule *schedule
+}
+
+// aggregationUnits the units of the aggregation period when it is given as a map, eg {hours: 1, minutes: 30}
+var aggregationUnits = map[string]time.Duration{
+	"weeks":   7 * 24 * time.Hour,
+	"days":    24 * time.Hour,
+	"hours":   time.Hour,
+	"minutes": time.Minute,
+	"seconds": time.Second,
+}
+
+func parseAlertAggregation(v interface{}, key string, fields []string) (*AlertAggregation, error) {
+	a := &AlertAggregation{Key: key, SummaryTableFields: fields}
+	switch x := normalize(v).(type) {
+	case nil:
+		return nil, nil
+	case string:
+		d, err := DurationStr(x).Duration()
+		if err != nil {
+			return nil, fmt.Errorf("parse aggregation err: %s", err.Error())
+		}
+		a.Window = d
+	case map[string]interface{}:
+		if spec, ok := x["schedule"]; ok {
+			s, err := parseSchedule(fmt.Sprint(spec))
+			if err != nil {
+				return nil, fmt.Errorf("parse aggregation err: %s", err.Error())
+			}
+			if s.next(time.Now()).IsZero() {
+				return nil, fmt.Errorf("aggregation schedule: %v never occurs", spec)
+			}
+			a.schedule = s
+			break
+		}
+		for k, val := range x {
+			unit, ok := aggregationUnits[k]
+			if !ok {
+				return nil, fmt.Errorf("aggregation unit: %s not supported", k)
+			}
+			n, err := strconv.ParseFloat(fmt.Sprint(val), 64)
+			if err != nil {
+				return nil, fmt.Errorf("parse aggregation %s err: %s", k, err.Error())
+			}
+			a.Window += time.Duration(n * float64(unit))
+		}
+	default:
+		return nil, fmt.Errorf("aggregation: %v not supported", v)
+	}
+	if a.schedule == nil && a.Window <= 0 {
+		return nil, fmt.Errorf("aggregation must be greater than 0")
+	}
+	return a, nil
+}
+
+func validAggregation(rl Rule) error {
+	_, err := rl.GetAlertAggregation()
+	return err
+}
+
+// sendTime returns when matches aggregated from now are sent.
+func (a *AlertAggregation) sendTime(now time.Time) time.Time {
+	if a.schedule != nil {
+		return a.schedule.next(now)
+	}
+	return now.Add(a.Window)
+}
+
+// keyValue returns the aggregation_key value of m.
+func (a *AlertAggregation) keyValue(m Match) string {
+	if a.Key == "" {
+		return ""
+	}
+	v, ok := matchField(m, a.Key)
+	if !ok || v == nil {
+		return "_missing"
+	}
+	return fieldString(v)
+}
+
+// pendingAggregate the matches held for an aggregated alert.
+type pendingAggregate struct {
+	rl    Rule
+	index string
+	id    string // the id of the alert in the writeback index, empty until it is written
+	rec   AlertRecord
+}
+
+// aggregate holds m until the aggregated alert of its aggregation_key value is sent.
+func (e *ElasticAlerter) aggregate(ctx context.Context, rl Rule, agg *AlertAggregation, m Match) {
+	value := agg.keyValue(m)
+	key := Concat(rl.GetName(), ".", value)
+	now := time.Now()
+
+	p, ok := e.aggregates[key]
+	if !ok {
+		var err error
+		if p, err = e.findAggregate(ctx, rl, value, now); err != nil {
+			log.Printf("rule: %s find aggregate: %s err: %s", rl.GetName(), key, err.Error())
+		}
+	}
+	if p == nil {
+		p = &pendingAggregate{
+			index: e.writebackIndex(writebackAlert),
+			rec: AlertRecord{
+				RuleName:       rl.GetName(),
+				Timestamp:      now,
+				AlertTime:      agg.sendTime(now),
+				AggregationKey: value,
+			},
+		}
+	}
+	p.rl = rl
+	p.rec.Matches = append(p.rec.Matches, m)
+	e.aggregates[key] = p
+
+	if e.cfg.MaxAggregation > 0 && len(p.rec.Matches) >= e.cfg.MaxAggregation {
+		p.rec.AlertTime = now
+		e.flushAggregate(ctx, key, p)
+		return
+	}
+	e.saveAggregate(ctx, p, false)
+}
+
+// findAggregate returns the aggregate of the aggregation_key value of rl held in the writeback index.
+func (e *ElasticAlerter) findAggregate(ctx context.Context, rl Rule, value string, now time.Time) (*pendingAggregate, error) {
+	if e.cfg.WritebackIndex == "" {
+		return nil, nil
+	}
+	query := elastic.NewBoolQuery().Filter(
+		elastic.NewTermQuery("rule_name", rl.GetName()),
+		elastic.NewTermQuery("aggregation_key", value),
+		elastic.NewTermQuery("alert_sent", false),
+		elastic.NewTermQuery("abandoned", false),
+		elastic.NewRangeQuery("alert_time").Gt(toMillis(now)).Format("epoch_millis"),
+	)
+	res, err := e.esClient.Search(e.alertsIndex()).Query(query).Sort("alert_time", true).Size(1).Do(ctx)
+	if elastic.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("search aggregate err: %s", err.Error())
+	}
+	if res.Hits == nil || len(res.Hits.Hits) == 0 {
+		return nil, nil
+	}
+
+	hit := res.Hits.Hits[0]
+	p := &pendingAggregate{index: hit.Index, id: hit.Id}
+	if err := json.Unmarshal(hit.Source, &p.rec); err != nil {
+		return nil, fmt.Errorf("decode aggregate: %s err: %s", hit.Id, err.Error())
+	}
+	return p, nil
+}
+
+// saveAggregate writes p to the writeback index, with refresh sendPendingAlerts sees the update at once.
+func (e *ElasticAlerter) saveAggregate(ctx context.Context, p *pendingAggregate, refresh bool) {
+	if p.id == "" {
+		id, err := e.writeback(ctx, writebackAlert, p.rec)
+		if err != nil {
+			log.Printf("rule: %s write aggregate err: %s", p.rec.RuleName, err.Error())
+		}
+		p.id = id
+		return
+	}
+	if err := e.rewrite(ctx, p.index, p.id, p.rec, refresh); err != nil {
+		log.Printf("rule: %s write aggregate err: %s", p.rec.RuleName, err.Error())
+	}
+}
+
+// flushAggregate sends the matches of p as one alert, failed alerters are retried by sendPendingAlerts.
+func (e *ElasticAlerter) flushAggregate(ctx context.Context, key string, p *pendingAggregate) {
+	delete(e.aggregates, key)
+
+	failed, err := e.sendAlert(ctx, p.rl, nil, p.rec.Matches)
+	p.rec.Failed = failed
+	p.rec.AlertSent = len(failed) == 0
+	if err != nil {
+		p.rec.AlertException = err.Error()
+		log.Printf("rule: %s aggregated alert err: %s", p.rec.RuleName, err.Error())
+	}
+	e.saveAggregate(ctx, p, true)
+}
+
+// sendDueAggregates sends the aggregates whose period ended.
+func (e *ElasticAlerter) sendDueAggregates(ctx context.Context) {
+	now := time.Now()
+	for key, p := range e.aggregates {
+		if !now.Before(p.rec.AlertTime) {
+			e.flushAggregate(ctx, key, p)
+		}
+	}
+}
+
+// summaryTable counts the matches by the values of summary_table_fields, it is empty unless the rule aggregates.
+func summaryTable(rl Rule, matches []Match) string {
+	agg, err := rl.GetAlertAggregation()
+	if err != nil || agg == nil || len(agg.SummaryTableFields) == 0 {
+		return ""
+	}
+
+	counts := make(map[string]int)
+	rows := make(map[string][]string)
+	for _, m := range matches {
+		row := make([]string, len(agg.SummaryTableFields))
+		for i, field := range agg.SummaryTableFields {
+			row[i] = missingValue
+			if v, ok := matchField(m, field); ok && v != nil {
+				row[i] = fieldString(v)
+			}
+		}
+		k := strings.Join(row, "\x00")
+		counts[k]++
+		rows[k] = row
+	}
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Aggregation resulted in the following data for summary_table_fields ==> %s:\n\n",
+		strings.Join(agg.SummaryTableFields, ", "))
+	tw := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "%s\tcount\n", strings.Join(agg.SummaryTableFields, "\t"))
+	for _, k := range keys {
+		fmt.Fprintf(tw, "%s\t%d\n", strings.Join(rows[k], "\t"), counts[k])
+	}
+	tw.Flush()
+	return b.String()
+}
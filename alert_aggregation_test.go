@@ -0,0 +1,229 @@
+/**
+ * Created by GoLand.
+ * @author: clyde
+ * @date: 2021/11/24 下午4:30
+ * @note:
+ */
+
+package elastalert
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// recordAlerter keeps the matches of each alert.
+type recordAlerter struct {
+	alerts [][]Match
+}
+
+func (a *recordAlerter) Alert(ctx context.Context, rl Rule, matches []Match) error {
+	a.alerts = append(a.alerts, matches)
+	return nil
+}
+
+func TestParseAlertAggregation(t *testing.T) {
+	cases := []struct {
+		v      interface{}
+		window time.Duration
+		sched  bool
+	}{
+		{"10m", 10 * time.Minute, false},
+		{map[string]interface{}{"hours": 1, "minutes": 30}, 90 * time.Minute, false},
+		{map[interface{}]interface{}{"days": "0.5"}, 12 * time.Hour, false},
+		{map[string]interface{}{"schedule": "0 9 * * mon-fri"}, 0, true},
+	}
+	for i, c := range cases {
+		a, err := parseAlertAggregation(c.v, "", nil)
+		if err != nil {
+			t.Fatalf("case %d: %s", i, err.Error())
+		}
+		if a.Window != c.window || (a.schedule != nil) != c.sched {
+			t.Errorf("case %d: unexpected aggregation: %+v", i, a)
+		}
+	}
+
+	if a, err := parseAlertAggregation(nil, "host", nil); a != nil || err != nil {
+		t.Errorf("expected no aggregation, got %+v %v", a, err)
+	}
+	for _, v := range []interface{}{"0m", map[string]interface{}{"fortnights": 1}, map[string]interface{}{"schedule": "9 * *"}, map[string]interface{}{"schedule": "0 0 31 2 *"}, 10} {
+		if _, err := parseAlertAggregation(v, "", nil); err == nil {
+			t.Errorf("expected aggregation: %v to be invalid", v)
+		}
+	}
+}
+
+func TestAggregate(t *testing.T) {
+	recorder := &recordAlerter{}
+	registerTestAlerter(t, "test_aggregated", func(cfg *Config, rl Rule) (Alerter, error) { return recorder, nil })
+
+	held := AlertRecord{
+		RuleName:       "errors",
+		AlertTime:      time.Now().Add(time.Hour),
+		AggregationKey: "web-4",
+		Matches:        []Match{{Data: map[string]interface{}{"host": "web-4", "status": 500}}},
+	}
+	heldDoc, _ := json.Marshal(held)
+	f, client := newFakeEs(t, func(method, path string, body []byte) (int, string) {
+		if !strings.HasSuffix(path, "/_search") {
+			return http.StatusCreated, `{"_id": "1", "result": "created"}`
+		}
+		var hits string
+		if strings.Contains(string(body), `"web-4"`) {
+			hits = `{"_index": "elastalert", "_id": "p1", "_source": ` + string(heldDoc) + `}`
+		}
+		return http.StatusOK, `{"hits": {"total": {"value": 1}, "hits": [` + hits + `]}}`
+	})
+	defer f.server.Close()
+
+	rl := &RuleBase{
+		Name:               "errors",
+		Index:              "logs-*",
+		Alert:              []string{"test_aggregated"},
+		Realert:            "0m",
+		Aggregation:        "1h",
+		AggregationKey:     "host",
+		SummaryTableFields: []string{"host", "status"},
+	}
+	e := newTestAlerter(&Config{WritebackIndex: "elastalert", MaxAggregation: 3}, client)
+	ctx := context.Background()
+
+	match := func(host string, status int) Match {
+		return Match{Timestamp: time.Now(), Data: map[string]interface{}{"host": host, "status": status}}
+	}
+	e.handleMatches(ctx, rl, []Match{match("web-1", 500), match("web-1", 502), match("web-2", 500), match("web-4", 500)})
+	if len(recorder.alerts) != 0 {
+		t.Fatalf("expected matches to be held, got %d alerts", len(recorder.alerts))
+	}
+	if len(e.aggregates) != 3 {
+		t.Fatalf("expected 3 aggregates, got %d", len(e.aggregates))
+	}
+	if docs := f.find(http.MethodPut, "/elastalert/_doc/p1"); len(docs) != 1 || !strings.Contains(string(docs[0]), `"aggregation_key":"web-4"`) {
+		t.Errorf("expected the held aggregate of web-4 to be updated: %q", docs)
+	}
+
+	// max_aggregation sends at once
+	e.handleMatches(ctx, rl, []Match{match("web-1", 500)})
+	if len(recorder.alerts) != 1 || len(recorder.alerts[0]) != 3 {
+		t.Fatalf("expected 3 matches of web-1 sent at max_aggregation, got %v", recorder.alerts)
+	}
+	if len(e.aggregates) != 2 {
+		t.Errorf("expected 2 aggregates left, got %d", len(e.aggregates))
+	}
+
+	e.sendDueAggregates(ctx)
+	if len(recorder.alerts) != 1 {
+		t.Errorf("expected no aggregate to be due, got %d alerts", len(recorder.alerts))
+	}
+	for _, p := range e.aggregates {
+		p.rec.AlertTime = time.Now().Add(-time.Second)
+	}
+	e.sendDueAggregates(ctx)
+	if len(recorder.alerts) != 3 || len(e.aggregates) != 0 {
+		t.Errorf("expected the due aggregates to be sent, got %d alerts %d left", len(recorder.alerts), len(e.aggregates))
+	}
+	var rec AlertRecord
+	updates := f.find(http.MethodPut, "/elastalert/_doc/p1")
+	if err := json.Unmarshal(updates[len(updates)-1], &rec); err != nil || !rec.AlertSent || len(rec.Matches) != 2 {
+		t.Errorf("unexpected aggregate of web-4: %s", updates[len(updates)-1])
+	}
+
+	rl.AlertText = "errors"
+	rl.AlertTextType = "aggregation_summary_only"
+	body, err := AlertBody(rl, recorder.alerts[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "Aggregation resulted in the following data for summary_table_fields ==> host, status:\n\n" +
+		"host   status  count\n" +
+		"web-1  500     2\n" +
+		"web-1  502     1\n"
+	if body != expected {
+		t.Errorf("expected body:\n%s\ngot:\n%s", expected, body)
+	}
+}
+
+func TestAggregateSentOnce(t *testing.T) {
+	recorder := &recordAlerter{}
+	registerTestAlerter(t, "test_aggregated_once", func(cfg *Config, rl Rule) (Alerter, error) { return recorder, nil })
+
+	// searches only see the writes refreshed with wait_for, like es before its refresh interval
+	var (
+		f       *fakeEs
+		ids     int
+		visible = make(map[string]string)
+	)
+	f, client := newFakeEs(t, func(method, path string, body []byte) (int, string) {
+		if strings.HasSuffix(path, "/_search") {
+			var hits []string
+			for id, doc := range visible {
+				var rec AlertRecord
+				json.Unmarshal([]byte(doc), &rec)
+				if strings.Contains(string(body), "aggregation_key") && !strings.Contains(string(body), `"`+rec.AggregationKey+`"`) {
+					continue
+				}
+				if !rec.AlertSent {
+					hits = append(hits, `{"_index": "elastalert", "_id": "`+id+`", "_source": `+doc+`}`)
+				}
+			}
+			return http.StatusOK, `{"hits": {"total": {"value": 1}, "hits": [` + strings.Join(hits, ",") + `]}}`
+		}
+		id := path[strings.LastIndex(path, "/")+1:]
+		if method == http.MethodPost {
+			ids++
+			id = fmt.Sprintf("p%d", ids)
+			visible[id] = string(body)
+		}
+		f.mu.Lock()
+		refresh := strings.Contains(f.requests[len(f.requests)-1].query, "refresh=wait_for")
+		f.mu.Unlock()
+		if method == http.MethodPut && refresh {
+			visible[id] = string(body)
+		}
+		return http.StatusCreated, `{"_id": "` + id + `", "result": "created"}`
+	})
+	defer f.server.Close()
+
+	rl := &RuleBase{
+		Name:           "errors",
+		Index:          "logs-*",
+		Alert:          []string{"test_aggregated_once"},
+		Realert:        "0m",
+		Aggregation:    "1h",
+		AggregationKey: "host",
+	}
+	e := newTestAlerter(&Config{WritebackIndex: "elastalert", AlertTimeLimit: "2d"}, client)
+	e.rulesLoader = staticRulesLoader{rl}
+	ctx := context.Background()
+
+	e.handleMatches(ctx, rl, []Match{
+		{Data: map[string]interface{}{"host": "web-1"}},
+		{Data: map[string]interface{}{"host": "web-2"}},
+	})
+	// web-1 is due, web-2 becomes due between sendDueAggregates and sendPendingAlerts
+	for _, p := range e.aggregates {
+		if p.rec.AggregationKey == "web-1" {
+			p.rec.AlertTime = time.Now().Add(-time.Second)
+		}
+	}
+	e.sendDueAggregates(ctx)
+	e.sendPendingAlerts(ctx)
+	if len(recorder.alerts) != 1 || len(e.aggregates) != 1 {
+		t.Fatalf("expected web-1 sent once and web-2 held, got %d alerts %d held", len(recorder.alerts), len(e.aggregates))
+	}
+
+	e.handleMatches(ctx, rl, []Match{{Data: map[string]interface{}{"host": "web-2"}}})
+	for _, p := range e.aggregates {
+		p.rec.AlertTime = time.Now().Add(-time.Second)
+	}
+	e.sendDueAggregates(ctx)
+	e.sendPendingAlerts(ctx)
+	if len(recorder.alerts) != 2 || len(recorder.alerts[1]) != 2 {
+		t.Errorf("expected the 2 matches of web-2 sent once, got %v", recorder.alerts)
+	}
+}
@@ -0,0 +1,175 @@
+/**
+ * Created by GoLand.
+ * @author: clyde
+ * @date: 2021/11/19 上午10:20
+ * @note: every alert is recorded in the writeback index, those some alerters failed to send are retried
+ * on the following ticks until alert_time_limit, then abandoned
+ */
+
+package elastalert
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/olivere/elastic/v7"
+	"log"
+	"strings"
+	"time"
+)
+
+// AlertRecord is an alert written to the writeback index.
+type AlertRecord struct {
+	RuleName  string    `json:"rule_name"`
+	Timestamp time.Time `json:"@timestamp"`
+	Matches   []Match   `json:"match_body"`
+
+	// AlertTime when the alert was first sent, for an aggregated alert when it is due
+	AlertTime time.Time `json:"alert_time"`
+
+	// AggregationKey the aggregation_key value of an aggregated alert
+	AggregationKey string `json:"aggregation_key"`
+
+	// AlertSent whether all the alerters of the rule sent it
+	AlertSent bool `json:"alert_sent"`
+
+	// Failed the alerters which failed to send it, the ones retried
+	Failed []string `json:"failed,omitempty"`
+
+	AlertException string `json:"alert_exception,omitempty"`
+	Retries        int    `json:"retries"`
+
+	// Abandoned set once alert_time_limit expired, or the rule is gone, without the alert being sent
+	Abandoned bool `json:"abandoned"`
+}
+
+// alert sends the matches with every alerter of rl and records the outcome.
+func (e *ElasticAlerter) alert(ctx context.Context, rl Rule, matches []Match) {
+	now := time.Now()
+	rec := AlertRecord{
+		RuleName:  rl.GetName(),
+		Timestamp: now,
+		Matches:   matches,
+		AlertTime: now,
+	}
+	failed, err := e.sendAlert(ctx, rl, nil, matches)
+	rec.Failed = failed
+	rec.AlertSent = len(failed) == 0
+	if err != nil {
+		rec.AlertException = err.Error()
+		log.Printf("rule: %s alert err: %s", rl.GetName(), err.Error())
+	}
+	if _, err := e.writeback(ctx, writebackAlert, rec); err != nil {
+		log.Printf("rule: %s write alert err: %s", rl.GetName(), err.Error())
+	}
+}
+
+// sendAlert sends the matches with the alerters of rl named in only, or all of them when only is nil.
+// It returns the names of the alerters which failed.
+func (e *ElasticAlerter) sendAlert(ctx context.Context, rl Rule, only []string, matches []Match) ([]string, error) {
+	alerters, err := e.getAlerters(rl)
+	if err != nil {
+		return rl.GetAlert(), err
+	}
+
+	var failed, errs []string
+	for i, a := range alerters {
+		name := rl.GetAlert()[i]
+		if only != nil && !contains(only, name) {
+			continue
+		}
+		if err := a.Alert(ctx, rl, matches); err != nil {
+			failed = append(failed, name)
+			errs = append(errs, Concat(name, ": ", err.Error()))
+		}
+	}
+	if len(errs) > 0 {
+		return failed, fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return nil, nil
+}
+
+// sendPendingAlerts retries the alerts not sent yet, aggregated ones once due, and abandons those older than alert_time_limit.
+func (e *ElasticAlerter) sendPendingAlerts(ctx context.Context) {
+	if e.cfg.WritebackIndex == "" {
+		return
+	}
+	limit, err := e.cfg.AlertTimeLimit.Duration()
+	if err != nil {
+		log.Printf("parse alert_time_limit err: %s", err.Error())
+		return
+	}
+
+	query := elastic.NewBoolQuery().Filter(
+		elastic.NewTermQuery("alert_sent", false),
+		elastic.NewTermQuery("abandoned", false),
+		elastic.NewRangeQuery("alert_time").Lte(toMillis(time.Now())).Format("epoch_millis"),
+	)
+	res, err := e.esClient.Search(e.alertsIndex()).Query(query).Sort("alert_time", true).Size(1000).Do(ctx)
+	if elastic.IsNotFound(err) {
+		return
+	}
+	if err != nil {
+		log.Printf("search pending alerts err: %s", err.Error())
+		return
+	}
+	if res.Hits == nil || len(res.Hits.Hits) == 0 {
+		return
+	}
+
+	// the aggregates still held are sent by sendDueAggregates
+	held := make(map[string]bool)
+	for _, p := range e.aggregates {
+		if p.id != "" {
+			held[p.id] = true
+		}
+	}
+	rules := make(map[string]Rule)
+	for _, rl := range e.rulesLoader.Load() {
+		rules[rl.GetName()] = rl
+	}
+	for _, hit := range res.Hits.Hits {
+		if held[hit.Id] {
+			continue
+		}
+		var rec AlertRecord
+		if err := json.Unmarshal(hit.Source, &rec); err != nil {
+			log.Printf("decode alert: %s err: %s", hit.Id, err.Error())
+			continue
+		}
+
+		rl, ok := rules[rec.RuleName]
+		switch {
+		case !ok:
+			rec.Abandoned = true
+			rec.AlertException = Concat("rule: ", rec.RuleName, " is not loaded")
+		case limit > 0 && time.Since(rec.AlertTime) > limit:
+			rec.Abandoned = true
+		default:
+			failed, err := e.sendAlert(ctx, rl, rec.Failed, rec.Matches)
+			rec.Retries++
+			rec.Failed = failed
+			rec.AlertSent = len(failed) == 0
+			rec.AlertException = ""
+			if err != nil {
+				rec.AlertException = err.Error()
+			}
+		}
+		if rec.Abandoned {
+			log.Printf("rule: %s alert: %s abandoned after %d retries", rec.RuleName, hit.Id, rec.Retries)
+		}
+
+		if err := e.rewrite(ctx, hit.Index, hit.Id, rec, false); err != nil {
+			log.Printf("update alert: %s err: %s", hit.Id, err.Error())
+		}
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
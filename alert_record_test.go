@@ -0,0 +1,108 @@
+/**
+ * Created by GoLand.
+ * @author: clyde
+ * @date: 2021/11/19 下午3:45
+ * @note:
+ */
+
+package elastalert
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+type staticRulesLoader []Rule
+
+func (l staticRulesLoader) Load() []Rule {
+	return l
+}
+
+// registerTestAlerter registers f under name until the end of the test.
+func registerTestAlerter(t *testing.T, name string, f AlerterFactory) {
+	RegisterAlerter(name, f)
+	t.Cleanup(func() {
+		alertersMu.Lock()
+		defer alertersMu.Unlock()
+		delete(alerters, name)
+	})
+}
+
+// countAlerter counts its alerts, failing the first fails of them.
+type countAlerter struct {
+	sent  int
+	fails int
+}
+
+func (a *countAlerter) Alert(ctx context.Context, rl Rule, matches []Match) error {
+	if a.fails > 0 {
+		a.fails--
+		return fmt.Errorf("relay unavailable")
+	}
+	a.sent++
+	return nil
+}
+
+func TestPendingAlerts(t *testing.T) {
+	steady, flaky := &countAlerter{}, &countAlerter{fails: 1}
+	registerTestAlerter(t, "test_steady", func(cfg *Config, rl Rule) (Alerter, error) { return steady, nil })
+	registerTestAlerter(t, "test_flaky", func(cfg *Config, rl Rule) (Alerter, error) { return flaky, nil })
+
+	var pending []string
+	f, client := newFakeEs(t, func(method, path string, body []byte) (int, string) {
+		if strings.HasSuffix(path, "/_search") {
+			return http.StatusOK, `{"hits": {"total": {"value": 2}, "hits": [` + strings.Join(pending, ",") + `]}}`
+		}
+		return http.StatusCreated, `{"_id": "1", "result": "created"}`
+	})
+	defer f.server.Close()
+
+	rl := &RuleBase{Name: "errors", Index: "logs-*", Alert: []string{"test_steady", "test_flaky"}}
+	e := newTestAlerter(&Config{WritebackIndex: "elastalert", AlertTimeLimit: "2d"}, client)
+	e.rulesLoader = staticRulesLoader{rl}
+	ctx := context.Background()
+
+	e.alert(ctx, rl, []Match{{Timestamp: time.Now(), QueryKey: "web-1"}})
+	docs := f.find(http.MethodPost, "/elastalert/_doc")
+	if len(docs) != 1 {
+		t.Fatalf("expected 1 alert doc, got %d", len(docs))
+	}
+	var rec AlertRecord
+	if err := json.Unmarshal(docs[0], &rec); err != nil {
+		t.Fatal(err)
+	}
+	if rec.AlertSent || len(rec.Failed) != 1 || rec.Failed[0] != "test_flaky" || !strings.Contains(rec.AlertException, "relay unavailable") {
+		t.Errorf("unexpected alert: %+v", rec)
+	}
+
+	old := rec
+	old.AlertTime = time.Now().Add(-72 * time.Hour)
+	oldDoc, _ := json.Marshal(old)
+	pending = []string{
+		`{"_index": "elastalert", "_id": "a1", "_source": ` + string(docs[0]) + `}`,
+		`{"_index": "elastalert", "_id": "a2", "_source": ` + string(oldDoc) + `}`,
+	}
+	e.sendPendingAlerts(ctx)
+
+	if steady.sent != 1 || flaky.sent != 1 {
+		t.Errorf("expected only the failed alerter to be retried, sent: %d %d", steady.sent, flaky.sent)
+	}
+	for id, check := range map[string]func(AlertRecord) bool{
+		"a1": func(r AlertRecord) bool { return r.AlertSent && r.Retries == 1 && len(r.Failed) == 0 && !r.Abandoned },
+		"a2": func(r AlertRecord) bool { return !r.AlertSent && r.Abandoned },
+	} {
+		updates := f.find(http.MethodPut, "/elastalert/_doc/"+id)
+		if len(updates) != 1 {
+			t.Fatalf("expected alert %s to be updated once, got %d", id, len(updates))
+		}
+		var r AlertRecord
+		if err := json.Unmarshal(updates[0], &r); err != nil || !check(r) {
+			t.Errorf("unexpected alert %s: %s", id, updates[0])
+		}
+	}
+}
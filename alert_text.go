@@ -0,0 +1,269 @@
+/**
+ * Created by GoLand.
+ * @author: clyde
+ * @date: 2021/11/16 上午10:30
+ * @note: rendering of the alert subject and text shared by all the alerters. alert_subject and alert_text are
+ * text/template executed against the matches and the rule settings, eg
+ * alert_subject: "{{.Name}}: {{field \"host.name\"}} at {{formatTime \"15:04\" .Timestamp}}"
+ */
+
+package elastalert
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const missingValue = "<MISSING VALUE>"
+
+// validAlertText checks the alert_* settings of rl.
+func validAlertText(rl Rule) error {
+	switch rl.GetAlertTextType() {
+	case "", "alert_text_only", "exclude_fields", "aggregation_summary_only":
+	default:
+		return fmt.Errorf("alert_text_type: %s not supported", rl.GetAlertTextType())
+	}
+	subject, _ := rl.GetAlertSubject()
+	text, _ := rl.GetAlertText()
+	for name, t := range map[string]string{"alert_subject": subject, "alert_text": text} {
+		if _, err := template.New(name).Funcs(templateFuncs(nil)).Parse(t); err != nil {
+			return fmt.Errorf("parse %s err: %s", name, err.Error())
+		}
+	}
+	return nil
+}
+
+// AlertSubject renders the subject of an alert of rl, alert_subject when set.
+func AlertSubject(rl Rule, matches []Match) (string, error) {
+	subject, args := rl.GetAlertSubject()
+	if subject == "" {
+		if len(matches) > 0 && matches[0].QueryKey != nil {
+			return fmt.Sprintf("ElastAlert: %s - %v", rl.GetName(), matches[0].QueryKey), nil
+		}
+		return Concat("ElastAlert: ", rl.GetName()), nil
+	}
+	s, err := renderAlertTemplate("alert_subject", subject, args, rl, matches)
+	if err != nil {
+		return "", err
+	}
+	// a subject is a single line
+	return strings.TrimSpace(strings.NewReplacer("\r\n", " ", "\r", " ", "\n", " ").Replace(s)), nil
+}
+
+// AlertBody renders the text of an alert of rl: alert_text and the summary table of an aggregated alert,
+// followed by the fields of the matches, without their documents for exclude_fields, or alone for
+// alert_text_only. aggregation_summary_only renders the summary table only.
+func AlertBody(rl Rule, matches []Match) (string, error) {
+	if rl.GetAlertTextType() == "aggregation_summary_only" {
+		return summaryTable(rl, matches), nil
+	}
+
+	text, args := rl.GetAlertText()
+	if text == "" {
+		text = "{{.Name}}"
+	}
+	body, err := renderAlertTemplate("alert_text", text, args, rl, matches)
+	if err != nil {
+		return "", err
+	}
+	if table := summaryTable(rl, matches); table != "" {
+		body = Concat(body, "\n\n", table)
+	}
+
+	switch rl.GetAlertTextType() {
+	case "alert_text_only":
+		return body, nil
+	case "exclude_fields":
+		return Concat(body, "\n", matchText(matches, false)), nil
+	default:
+		return Concat(body, "\n", matchText(matches, true)), nil
+	}
+}
+
+// renderAlertTemplate renders text, then replaces {0}, {1}... by the values of args in the first match.
+func renderAlertTemplate(name, text string, args []string, rl Rule, matches []Match) (string, error) {
+	s, err := renderTemplate(name, text, rl, matches)
+	if err != nil || len(args) == 0 {
+		return s, err
+	}
+
+	pairs := make([]string, 0, 2*len(args))
+	for i, arg := range args {
+		value := missingValue
+		if len(matches) > 0 {
+			if v, ok := matchField(matches[0], arg); ok && v != nil {
+				value = fieldString(v)
+			}
+		}
+		pairs = append(pairs, Concat("{", strconv.Itoa(i), "}"), value)
+	}
+	return strings.NewReplacer(pairs...).Replace(s), nil
+}
+
+// renderTemplate executes the template text against the matches of rl.
+func renderTemplate(name, text string, rl Rule, matches []Match) (string, error) {
+	tpl, err := template.New(name).Funcs(templateFuncs(matches)).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("parse %s err: %s", name, err.Error())
+	}
+	var buf bytes.Buffer
+	if err := tpl.Execute(&buf, templateData(rl, matches)); err != nil {
+		return "", fmt.Errorf("execute %s err: %s", name, err.Error())
+	}
+	return buf.String(), nil
+}
+
+// templateData is what the templates are executed against, .Rule holds the settings of the rule file.
+func templateData(rl Rule, matches []Match) map[string]interface{} {
+	data := map[string]interface{}{
+		"Rule":       rl.GetSettings(),
+		"Name":       rl.GetName(),
+		"Index":      rl.GetIndex(),
+		"Matches":    matches,
+		"NumMatches": len(matches),
+	}
+	if len(matches) > 0 {
+		m := matches[0]
+		data["Match"] = m
+		data["QueryKey"] = m.QueryKey
+		data["Timestamp"] = m.Timestamp
+		data["Data"] = m.Data
+		if len(m.Documents) > 0 {
+			data["Doc"] = m.Documents[0]
+		}
+	}
+	return data
+}
+
+// templateFuncs the helpers of the templates:
+// field "a.b" the field of the first match, "" when missing
+// lookup . "a.b" the field of a document or match
+// formatTime "2006-01-02 15:04" t formats a time, rfc3339 string or epoch millis
+// json v, jsonIndent v dump v as json
+func templateFuncs(matches []Match) template.FuncMap {
+	return template.FuncMap{
+		"field": func(key string) interface{} {
+			if len(matches) == 0 {
+				return ""
+			}
+			if v, ok := matchField(matches[0], key); ok {
+				return v
+			}
+			return ""
+		},
+		"lookup": func(v interface{}, key string) interface{} {
+			switch x := v.(type) {
+			case Match:
+				v, _ := matchField(x, key)
+				return v
+			case map[string]interface{}:
+				v, _ := LookupField(x, key)
+				return v
+			}
+			return nil
+		},
+		"formatTime": formatTime,
+		"json": func(v interface{}) (string, error) {
+			data, err := json.Marshal(normalize(v))
+			return string(data), err
+		},
+		"jsonIndent": func(v interface{}) (string, error) {
+			data, err := json.MarshalIndent(normalize(v), "", "  ")
+			return string(data), err
+		},
+	}
+}
+
+// formatTime formats v with layout, rfc3339 when layout is empty.
+func formatTime(layout string, v interface{}) (string, error) {
+	if layout == "" {
+		layout = time.RFC3339
+	}
+	switch t := v.(type) {
+	case time.Time:
+		return t.Format(layout), nil
+	case string:
+		parsed, err := time.Parse(time.RFC3339Nano, t)
+		if err != nil {
+			return "", fmt.Errorf("parse time err: %s", err.Error())
+		}
+		return parsed.Format(layout), nil
+	case float64:
+		return fromMillis(int64(t)).Format(layout), nil
+	case int64:
+		return fromMillis(t).Format(layout), nil
+	case int:
+		return fromMillis(int64(t)).Format(layout), nil
+	}
+	return "", fmt.Errorf("formatTime: unsupported value %v", v)
+}
+
+// matchField looks key up in the match: query_key, timestamp, the data of the rule, then the first document.
+func matchField(m Match, key string) (interface{}, bool) {
+	switch key {
+	case "query_key":
+		return m.QueryKey, m.QueryKey != nil
+	case "timestamp":
+		return m.Timestamp, true
+	}
+	if v, ok := LookupField(m.Data, key); ok {
+		return v, true
+	}
+	if len(m.Documents) > 0 {
+		return LookupField(m.Documents[0], key)
+	}
+	return nil, false
+}
+
+// matchText is the plain text of the fields of the matches.
+func matchText(matches []Match, withDocs bool) string {
+	var b strings.Builder
+	for _, m := range matches {
+		b.WriteString("\n")
+		fmt.Fprintf(&b, "timestamp: %s\n", m.Timestamp.Format(time.RFC3339))
+		if m.QueryKey != nil {
+			fmt.Fprintf(&b, "query_key: %v\n", m.QueryKey)
+		}
+		writeFields(&b, m.Data)
+		if withDocs {
+			for _, doc := range m.Documents {
+				b.WriteString("\n")
+				writeFields(&b, doc)
+			}
+		}
+		b.WriteString("\n----------------------------------------\n")
+	}
+	return b.String()
+}
+
+// writeFields writes the fields sorted by key, nested values as json.
+func writeFields(b *strings.Builder, fields map[string]interface{}) {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(b, "%s: %s\n", k, fieldString(fields[k]))
+	}
+}
+
+// fieldString formats a field value, maps and slices as json.
+func fieldString(v interface{}) string {
+	switch x := v.(type) {
+	case time.Time:
+		return x.Format(time.RFC3339)
+	case map[string]interface{}, []interface{}:
+		data, err := json.Marshal(v)
+		if err == nil {
+			return string(data)
+		}
+	}
+	return fmt.Sprintf("%v", v)
+}
@@ -0,0 +1,96 @@
+/**
+ * Created by GoLand.
+ * @author: clyde
+ * @date: 2021/11/16 下午3:20
+ * @note:
+ */
+
+package elastalert
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAlertText(t *testing.T) {
+	ts := time.Date(2021, 11, 16, 8, 30, 0, 0, time.UTC)
+	m := Match{
+		Timestamp: ts,
+		QueryKey:  "web-1",
+		Documents: []map[string]interface{}{{"host": map[string]interface{}{"name": "web-1"}, "status": 500}},
+		Data:      map[string]interface{}{"num_hits": 3},
+	}
+	rl := &RuleBase{
+		Name:             "errors",
+		Index:            "logs-*",
+		AlertSubject:     `{{.Name}} on {0} at {{formatTime "15:04" .Timestamp}}`,
+		AlertSubjectArgs: []string{"host.name"},
+		AlertText:        `{{field "num_hits"}} hits, status {{lookup .Doc "status"}}, level {{.Rule.level}}, {0}, {{json .Data}}`,
+		AlertTextArgs:    []string{"missing"},
+		AlertTextType:    "alert_text_only",
+	}
+	rl.SetSettings(map[string]interface{}{"level": "high"})
+	if err := validAlertText(rl); err != nil {
+		t.Fatal(err)
+	}
+
+	subject, err := AlertSubject(rl, []Match{m})
+	if err != nil || subject != "errors on web-1 at 08:30" {
+		t.Errorf("unexpected subject: %q err: %v", subject, err)
+	}
+	body, err := AlertBody(rl, []Match{m})
+	if err != nil || body != `3 hits, status 500, level high, <MISSING VALUE>, {"num_hits":3}` {
+		t.Errorf("unexpected body: %q err: %v", body, err)
+	}
+
+	rl.AlertText, rl.AlertTextArgs, rl.AlertTextType = "", nil, "exclude_fields"
+	body, _ = AlertBody(rl, []Match{m})
+	if !strings.HasPrefix(body, "errors\n") || !strings.Contains(body, "num_hits: 3") || strings.Contains(body, "status") {
+		t.Errorf("unexpected body: %q", body)
+	}
+	rl.AlertTextType = ""
+	body, _ = AlertBody(rl, []Match{m})
+	if !strings.Contains(body, "status: 500") || !strings.Contains(body, `host: {"name":"web-1"}`) {
+		t.Errorf("unexpected body: %q", body)
+	}
+
+	rl.AlertTextType = "html"
+	if validAlertText(rl) == nil {
+		t.Errorf("expected alert_text_type html to be invalid")
+	}
+	rl.AlertTextType, rl.AlertSubject = "", "{{.Name"
+	if validAlertText(rl) == nil {
+		t.Errorf("expected an unparsable alert_subject to be invalid")
+	}
+}
+
+func TestAlertBodySummaryOnly(t *testing.T) {
+	matches := []Match{
+		{Data: map[string]interface{}{"host": "web-1"}},
+		{Data: map[string]interface{}{"host": "web-1"}},
+		{Data: map[string]interface{}{"host": "web-2"}},
+	}
+	rl := &RuleBase{
+		Name:               "errors",
+		AlertText:          "too many errors",
+		AlertTextType:      "aggregation_summary_only",
+		Aggregation:        "1h",
+		SummaryTableFields: []string{"host"},
+	}
+	table := "Aggregation resulted in the following data for summary_table_fields ==> host:\n\n" +
+		"host   count\n" +
+		"web-1  2\n" +
+		"web-2  1\n"
+
+	body, err := AlertBody(rl, matches)
+	if err != nil || body != table {
+		t.Errorf("expected only the summary table, got: %q err: %v", body, err)
+	}
+
+	rl.AlertTextType = "alert_text_only"
+	body, err = AlertBody(rl, matches)
+	if err != nil || body != "too many errors\n\n"+table {
+		t.Errorf("expected alert_text and the summary table, got: %q err: %v", body, err)
+	}
+}
@@ -0,0 +1,133 @@
+/**
+ * Created by GoLand.
+ * @author: clyde
+ * @date: 2021/11/05 上午10:12
+ * @note: registry of the alerters, rules pick them by name with the alert setting
+ */
+
+package elastalert
+
+import (
+	"context"
+	"fmt"
+	"github.com/mitchellh/mapstructure"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Alerter sends the matches of a rule somewhere.
+type Alerter interface {
+	Alert(ctx context.Context, rl Rule, matches []Match) error
+}
+
+// Resolver is implemented by alerters able to close what they opened. Resolve is called after every run
+// of the rule with all of its matches, including none.
+type Resolver interface {
+	Resolve(ctx context.Context, rl Rule, matches []Match) error
+}
+
+// AlerterFactory builds the alerter of a rule, options are usually decoded from the rule settings with decodeSettings.
+type AlerterFactory func(cfg *Config, rl Rule) (Alerter, error)
+
+var (
+	alertersMu sync.RWMutex
+	alerters   = make(map[string]AlerterFactory)
+)
+
+// RegisterAlerter makes an alerter available under name, a value of the alert setting in rule files.
+// It panics if name is registered twice or f is nil.
+func RegisterAlerter(name string, f AlerterFactory) {
+	alertersMu.Lock()
+	defer alertersMu.Unlock()
+
+	if f == nil {
+		panic(fmt.Sprintf("elastalert: alerter %s is nil", name))
+	}
+	if _, dup := alerters[name]; dup {
+		panic(fmt.Sprintf("elastalert: alerter %s registered twice", name))
+	}
+	alerters[name] = f
+}
+
+// LookupAlerter returns the alerter factory registered under name.
+func LookupAlerter(name string) (AlerterFactory, bool) {
+	alertersMu.RLock()
+	defer alertersMu.RUnlock()
+
+	f, ok := alerters[name]
+	return f, ok
+}
+
+// NewAlerters builds the alerters listed in the alert setting of rl.
+func NewAlerters(cfg *Config, rl Rule) ([]Alerter, error) {
+	var list []Alerter
+	for _, name := range rl.GetAlert() {
+		f, ok := LookupAlerter(name)
+		if !ok {
+			return nil, fmt.Errorf("alerter: %s not supported", name)
+		}
+		a, err := f(cfg, rl)
+		if err != nil {
+			return nil, fmt.Errorf("new alerter: %s err: %s", name, err.Error())
+		}
+		list = append(list, a)
+	}
+	return list, nil
+}
+
+// decodeSettings decodes the settings of rl into out the same way viper unmarshals rules.
+func decodeSettings(rl Rule, out interface{}) error {
+	return decodeMap(rl.GetSettings(), out)
+}
+
+// decodePrefixedSettings decodes the settings of rl starting with prefix into out, with the prefix trimmed from the keys.
+func decodePrefixedSettings(rl Rule, prefix string, out interface{}) error {
+	settings := make(map[string]interface{})
+	for k, v := range rl.GetSettings() {
+		if strings.HasPrefix(k, prefix) {
+			settings[strings.TrimPrefix(k, prefix)] = v
+		}
+	}
+	return decodeMap(settings, out)
+}
+
+func decodeMap(settings map[string]interface{}, out interface{}) error {
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook: mapstructure.StringToTimeDurationHookFunc(),
+		// a single value is taken for a list of one, strings are not split on commas
+		WeaklyTypedInput: true,
+		Result:           out,
+	})
+	if err != nil {
+		return err
+	}
+	return decoder.Decode(settings)
+}
+
+// incidentKey identifies the incident of a rule and query_key value, it is used as dedup key or alias.
+func incidentKey(rl Rule, queryKey interface{}) string {
+	if queryKey == nil {
+		return rl.GetName()
+	}
+	return fmt.Sprintf("%s:%v", rl.GetName(), queryKey)
+}
+
+// incidents the incident keys an alerter triggered, to resolve them once the rule stops matching.
+type incidents map[string]bool
+
+// stale returns the open incident keys without a match.
+func (o incidents) stale(rl Rule, matches []Match) []string {
+	current := make(map[string]bool, len(matches))
+	for _, m := range matches {
+		current[incidentKey(rl, m.QueryKey)] = true
+	}
+	var keys []string
+	for k := range o {
+		if !current[k] {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
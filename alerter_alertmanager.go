@@ -0,0 +1,136 @@
+/**
+ * Created by GoLand.
+ * @author: clyde
+ * @date: 2021/11/12 上午10:25
+ * @note: alertmanager alerter pushes the matches to alertmanager, settings are prefixed by alertmanager_
+ * @refer: https://prometheus.io/docs/alerting/latest/clients/
+ */
+
+package elastalert
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+func init() {
+	RegisterAlerter("alertmanager", newAlertmanagerAlerter)
+}
+
+type alertmanagerAlerter struct {
+	// Hosts one or more base urls of alertmanager, eg http://alertmanager:9093
+	Hosts []string `mapstructure:"hosts"`
+
+	// ApiPath The default is /api/v2/alerts
+	ApiPath string `mapstructure:"api_path"`
+
+	// Labels added to the alertname, elastalert_rule, index and query_key labels
+	Labels map[string]string `mapstructure:"labels"`
+
+	// Annotations text/template of the annotations, summary and description default to the alert subject and text
+	Annotations map[string]string `mapstructure:"annotations"`
+
+	BasicAuthLogin    string `mapstructure:"basic_auth_login"`
+	BasicAuthPassword string `mapstructure:"basic_auth_password"`
+
+	// Timeout The default is 10s
+	Timeout         time.Duration `mapstructure:"timeout"`
+	Proxy           string        `mapstructure:"proxy"`
+	CaCerts         string        `mapstructure:"ca_certs"`
+	IgnoreSslErrors bool          `mapstructure:"ignore_ssl_errors"`
+
+	client *http.Client
+}
+
+func newAlertmanagerAlerter(cfg *Config, rl Rule) (Alerter, error) {
+	a := &alertmanagerAlerter{
+		ApiPath: "/api/v2/alerts",
+		Timeout: 10 * time.Second,
+	}
+	if err := decodePrefixedSettings(rl, "alertmanager_", a); err != nil {
+		return nil, fmt.Errorf("decode alertmanager settings err: %s", err.Error())
+	}
+	if len(a.Hosts) == 0 {
+		return nil, fmt.Errorf("alertmanager_hosts is required")
+	}
+
+	client, err := newHttpClient(a.Timeout, a.CaCerts, a.IgnoreSslErrors, a.Proxy)
+	if err != nil {
+		return nil, err
+	}
+	a.client = client
+	return a, nil
+}
+
+func (a *alertmanagerAlerter) Alert(ctx context.Context, rl Rule, matches []Match) error {
+	alerts := make([]map[string]interface{}, 0, len(matches))
+	for _, m := range matches {
+		alert, err := a.alert(rl, m)
+		if err != nil {
+			return err
+		}
+		alerts = append(alerts, alert)
+	}
+	body, err := json.Marshal(alerts)
+	if err != nil {
+		return fmt.Errorf("json.Marshal alerts err: %s", err.Error())
+	}
+
+	var headers map[string]string
+	if a.BasicAuthLogin != "" {
+		auth := base64.StdEncoding.EncodeToString([]byte(a.BasicAuthLogin + ":" + a.BasicAuthPassword))
+		headers = map[string]string{"Authorization": "Basic " + auth}
+	}
+	for _, host := range a.Hosts {
+		if err := postJSON(ctx, a.client, strings.TrimSuffix(host, "/")+a.ApiPath, headers, body); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *alertmanagerAlerter) alert(rl Rule, m Match) (map[string]interface{}, error) {
+	labels := map[string]string{
+		"alertname":       rl.GetName(),
+		"elastalert_rule": rl.GetName(),
+		"index":           rl.GetIndex(),
+	}
+	if m.QueryKey != nil {
+		labels["query_key"] = fieldString(m.QueryKey)
+	}
+	for k, v := range a.Labels {
+		labels[k] = v
+	}
+
+	subject, err := AlertSubject(rl, []Match{m})
+	if err != nil {
+		return nil, err
+	}
+	text, err := AlertBody(rl, []Match{m})
+	if err != nil {
+		return nil, err
+	}
+
+	annotations := map[string]string{
+		"summary":     subject,
+		"description": text,
+	}
+	for k, text := range a.Annotations {
+		v, err := renderTemplate("alertmanager_annotations."+k, text, rl, []Match{m})
+		if err != nil {
+			return nil, err
+		}
+		annotations[k] = v
+	}
+
+	return map[string]interface{}{
+		"labels":      labels,
+		"annotations": annotations,
+		"startsAt":    m.Timestamp.Format(time.RFC3339),
+	}, nil
+}
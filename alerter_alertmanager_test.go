@@ -0,0 +1,92 @@
+/**
+ * Created by GoLand.
+ * @author: clyde
+ * @date: 2021/11/12 下午3:10
+ * @note:
+ */
+
+package elastalert
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAlertmanagerAlerter(t *testing.T) {
+	var paths []string
+	var alerts []struct {
+		Labels      map[string]string `json:"labels"`
+		Annotations map[string]string `json:"annotations"`
+		StartsAt    time.Time         `json:"startsAt"`
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		json.NewDecoder(r.Body).Decode(&alerts)
+	}))
+	defer ts.Close()
+
+	rl := &RuleBase{Name: "errors", Index: "logs-*", Alert: []string{"alertmanager"}}
+	rl.SetSettings(map[string]interface{}{
+		"alertmanager_hosts":       []interface{}{ts.URL, ts.URL + "/"},
+		"alertmanager_labels":      map[string]interface{}{"severity": "page"},
+		"alertmanager_annotations": map[string]interface{}{"summary": "{{.Name}} on {{.QueryKey}}"},
+	})
+	alerters, err := NewAlerters(&Config{}, rl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := Match{Timestamp: time.Now(), QueryKey: "web-1"}
+	if err := alerters[0].Alert(context.Background(), rl, []Match{m}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(paths) != 2 || paths[0] != "/api/v2/alerts" || paths[1] != "/api/v2/alerts" {
+		t.Errorf("unexpected paths: %v", paths)
+	}
+	if len(alerts) != 1 {
+		t.Fatalf("expected 1 alert, got %d", len(alerts))
+	}
+	labels, annotations := alerts[0].Labels, alerts[0].Annotations
+	if labels["alertname"] != "errors" || labels["index"] != "logs-*" || labels["query_key"] != "web-1" || labels["severity"] != "page" {
+		t.Errorf("unexpected labels: %v", labels)
+	}
+	if annotations["summary"] != "errors on web-1" || annotations["description"] == "" || alerts[0].StartsAt.IsZero() {
+		t.Errorf("unexpected annotations: %v", annotations)
+	}
+}
+
+func TestAlertmanagerAlerterKeyCase(t *testing.T) {
+	var alerts []struct {
+		Labels      map[string]string `json:"labels"`
+		Annotations map[string]string `json:"annotations"`
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&alerts)
+	}))
+	defer ts.Close()
+
+	rl := loadTestRule(t, `name: errors
+type: any
+index: logs-*
+alert: alertmanager
+alertmanager_hosts: `+ts.URL+`
+alertmanager_labels:
+  teamName: ops
+alertmanager_annotations:
+  runbookURL: https://runbooks.example.com/errors
+`)
+	alerters, err := NewAlerters(&Config{}, rl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := alerters[0].Alert(context.Background(), rl, []Match{{Timestamp: time.Now()}}); err != nil {
+		t.Fatal(err)
+	}
+	if len(alerts) != 1 || alerts[0].Labels["teamName"] != "ops" || alerts[0].Annotations["runbookURL"] == "" {
+		t.Errorf("expected the label and annotation names as written, got %+v", alerts)
+	}
+}
@@ -0,0 +1,138 @@
+/**
+ * Created by GoLand.
+ * @author: clyde
+ * @date: 2021/11/10 上午11:20
+ * @note: command alerter runs an external process, eg command: ["/opt/remediate.sh", "--host", "%(host.name)s"]
+ */
+
+package elastalert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+)
+
+func init() {
+	RegisterAlerter("command", newCommandAlerter)
+}
+
+// commandArgRe matches the %(field)s placeholders of the command arguments.
+var commandArgRe = regexp.MustCompile(`%\(([^)]+)\)s`)
+
+type commandAlerter struct {
+	// Command the argv to run, fields of the first match are interpolated with %(field)s.
+	// A string instead of a list is run by /bin/sh -c, with the interpolated values shell quoted
+	Command []string `mapstructure:"command"`
+
+	// PipeMatchJson write the matches to stdin as json, one per line
+	PipeMatchJson bool `mapstructure:"pipe_match_json"`
+
+	// PipeAlertText write the alert text to stdin
+	PipeAlertText bool `mapstructure:"pipe_alert_text"`
+
+	// CommandTimeout kill the process when it runs longer. The default is 60s
+	CommandTimeout time.Duration `mapstructure:"command_timeout"`
+
+	// FailOnNonZeroExit treat a non zero exit status as a failed alert. The default is true
+	FailOnNonZeroExit bool `mapstructure:"fail_on_non_zero_exit"`
+
+	shell bool
+}
+
+func newCommandAlerter(cfg *Config, rl Rule) (Alerter, error) {
+	a := &commandAlerter{
+		CommandTimeout:    60 * time.Second,
+		FailOnNonZeroExit: true,
+	}
+	if err := decodeSettings(rl, a); err != nil {
+		return nil, fmt.Errorf("decode command settings err: %s", err.Error())
+	}
+	// decided from the setting, a document field must not turn an argument into a shell line
+	if s, ok := rl.GetSettings()["command"].(string); ok {
+		a.Command, a.shell = []string{s}, true
+	}
+	if len(a.Command) == 0 || a.Command[0] == "" {
+		return nil, fmt.Errorf("command is required")
+	}
+	if a.PipeMatchJson && a.PipeAlertText {
+		return nil, fmt.Errorf("pipe_match_json and pipe_alert_text are exclusive")
+	}
+	return a, nil
+}
+
+// args returns the argv with the placeholders replaced by fields of m, missing fields are replaced by empty strings.
+func (a *commandAlerter) args(m Match) []string {
+	args := make([]string, len(a.Command))
+	for i, arg := range a.Command {
+		args[i] = commandArgRe.ReplaceAllStringFunc(arg, func(s string) string {
+			value := ""
+			if v, ok := matchField(m, commandArgRe.FindStringSubmatch(s)[1]); ok && v != nil {
+				if t, ok := v.(time.Time); ok {
+					value = t.Format(time.RFC3339)
+				} else {
+					value = fieldString(v)
+				}
+			}
+			if a.shell {
+				return shellQuote(value)
+			}
+			return value
+		})
+	}
+	if a.shell {
+		return []string{"/bin/sh", "-c", args[0]}
+	}
+	return args
+}
+
+// shellQuote quotes s as a single word of /bin/sh.
+func shellQuote(s string) string {
+	return Concat("'", strings.Replace(s, "'", `'\''`, -1), "'")
+}
+
+func (a *commandAlerter) Alert(ctx context.Context, rl Rule, matches []Match) error {
+	if len(matches) == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, a.CommandTimeout)
+	defer cancel()
+
+	args := a.args(matches[0])
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+
+	var stdin bytes.Buffer
+	if a.PipeMatchJson {
+		enc := json.NewEncoder(&stdin)
+		for _, m := range matches {
+			if err := enc.Encode(m); err != nil {
+				return fmt.Errorf("json.Encode match err: %s", err.Error())
+			}
+		}
+	}
+	if a.PipeAlertText {
+		text, err := AlertBody(rl, matches)
+		if err != nil {
+			return err
+		}
+		stdin.WriteString(text)
+	}
+	cmd.Stdin = &stdin
+
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		if _, ok := err.(*exec.ExitError); ok && !a.FailOnNonZeroExit && ctx.Err() == nil {
+			return nil
+		}
+		if len(out) > 512 {
+			out = out[:512]
+		}
+		return fmt.Errorf("run command: %s err: %s output: %s", args[0], err.Error(), out)
+	}
+	return nil
+}
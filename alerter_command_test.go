@@ -0,0 +1,105 @@
+/**
+ * Created by GoLand.
+ * @author: clyde
+ * @date: 2021/11/10 下午2:40
+ * @note:
+ */
+
+package elastalert
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCommandAlerter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "command")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	out := filepath.Join(dir, "out")
+
+	rl := &RuleBase{Name: "errors", Index: "logs-*", Alert: []string{"command"}}
+	rl.SetSettings(map[string]interface{}{
+		"command":         []interface{}{"/bin/sh", "-c", "cat > " + out + "; echo %(host.name)s >> " + out},
+		"pipe_match_json": true,
+	})
+	alerters, err := NewAlerters(&Config{}, rl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := Match{Timestamp: time.Now(), Documents: []map[string]interface{}{{"host": map[string]interface{}{"name": "web-1"}}}}
+	if err := alerters[0].Alert(context.Background(), rl, []Match{m}); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	var piped Match
+	if len(lines) != 2 || json.Unmarshal([]byte(lines[0]), &piped) != nil || len(piped.Documents) != 1 {
+		t.Fatalf("unexpected stdin: %s", data)
+	}
+	if lines[1] != "web-1" {
+		t.Errorf("argument not interpolated: %s", lines[1])
+	}
+
+	rl.Settings = map[string]interface{}{"command": "exit 3", "command_timeout": "5s"}
+	alerters, err = NewAlerters(&Config{}, rl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := alerters[0].Alert(context.Background(), rl, []Match{m}); err == nil {
+		t.Errorf("expected an error on non zero exit")
+	}
+	rl.Settings["fail_on_non_zero_exit"] = false
+	alerters, _ = NewAlerters(&Config{}, rl)
+	if err := alerters[0].Alert(context.Background(), rl, []Match{m}); err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+	}
+}
+
+func TestCommandAlerterHostileField(t *testing.T) {
+	dir, err := ioutil.TempDir("", "command")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	pwned, out := filepath.Join(dir, "pwned"), filepath.Join(dir, "out")
+	host := "x'; touch " + pwned + "; $(touch " + pwned + ")"
+	m := Match{Timestamp: time.Now(), Data: map[string]interface{}{"host": host}}
+
+	rl := &RuleBase{Name: "errors", Index: "logs-*", Alert: []string{"command"}}
+	rl.SetSettings(map[string]interface{}{"command": []interface{}{"/opt/notify-%(host)s"}})
+	alerters, err := NewAlerters(&Config{}, rl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if args := alerters[0].(*commandAlerter).args(m); len(args) != 1 || args[0] != "/opt/notify-"+host {
+		t.Errorf("expected a single argv entry, got %q", args)
+	}
+
+	rl.Settings = map[string]interface{}{"command": "echo %(host)s > " + out}
+	alerters, err = NewAlerters(&Config{}, rl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := alerters[0].Alert(context.Background(), rl, []Match{m}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(pwned); !os.IsNotExist(err) {
+		t.Errorf("field value was run by the shell")
+	}
+	if data, _ := ioutil.ReadFile(out); strings.TrimSpace(string(data)) != host {
+		t.Errorf("expected the field value echoed as is, got: %s", data)
+	}
+}
@@ -0,0 +1,126 @@
+/**
+ * Created by GoLand.
+ * @author: clyde
+ * @date: 2021/11/15 上午10:10
+ * @note: debug alerter logs the matches, file alerter appends them as json lines to a file,
+ * both show what would be sent without touching real notification channels
+ */
+
+package elastalert
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+func init() {
+	RegisterAlerter("debug", func(cfg *Config, rl Rule) (Alerter, error) {
+		return debugAlerter{}, nil
+	})
+	RegisterAlerter("file", newFileAlerter)
+}
+
+type debugAlerter struct{}
+
+func (debugAlerter) Alert(ctx context.Context, rl Rule, matches []Match) error {
+	subject, err := AlertSubject(rl, matches)
+	if err != nil {
+		return err
+	}
+	text, err := AlertBody(rl, matches)
+	if err != nil {
+		return err
+	}
+	log.Printf("alert for rule: %s\n%s\n\n%s", rl.GetName(), subject, text)
+	return nil
+}
+
+type fileAlerter struct {
+	// FilePath the file the matches are appended to
+	FilePath string `mapstructure:"file_path"`
+
+	// FileMaxSize rotate the file once it is larger, in bytes. The default is 0, which means no rotation
+	FileMaxSize int64 `mapstructure:"file_max_size"`
+
+	// FileMaxBackups the number of rotated files kept as file_path.1 to file_path.N. The default is 3
+	FileMaxBackups int `mapstructure:"file_max_backups"`
+
+	mu sync.Mutex
+}
+
+// fileRecord is a line of the file alerter.
+type fileRecord struct {
+	Rule    string    `json:"rule"`
+	AlertAt time.Time `json:"alert_at"`
+	Match
+}
+
+func newFileAlerter(cfg *Config, rl Rule) (Alerter, error) {
+	a := &fileAlerter{FileMaxBackups: 3}
+	if err := decodeSettings(rl, a); err != nil {
+		return nil, fmt.Errorf("decode file settings err: %s", err.Error())
+	}
+	if a.FilePath == "" {
+		return nil, fmt.Errorf("file_path is required")
+	}
+	if a.FileMaxBackups < 1 {
+		a.FileMaxBackups = 1
+	}
+	return a, nil
+}
+
+func (a *fileAlerter) Alert(ctx context.Context, rl Rule, matches []Match) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err := a.rotate(); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(a.FilePath), 0755); err != nil {
+		return fmt.Errorf("create dir err: %s", err.Error())
+	}
+	f, err := os.OpenFile(a.FilePath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("open file err: %s", err.Error())
+	}
+	defer f.Close()
+
+	enc := json.NewEncoder(f)
+	now := time.Now()
+	for _, m := range matches {
+		if err := enc.Encode(fileRecord{Rule: rl.GetName(), AlertAt: now, Match: m}); err != nil {
+			return fmt.Errorf("write match err: %s", err.Error())
+		}
+	}
+	return nil
+}
+
+// rotate shifts file_path.N-1 to file_path.N down to file_path to file_path.1 when file_path exceeds file_max_size.
+func (a *fileAlerter) rotate() error {
+	if a.FileMaxSize <= 0 {
+		return nil
+	}
+	fi, err := os.Stat(a.FilePath)
+	if err != nil || fi.Size() < a.FileMaxSize {
+		return nil
+	}
+
+	for i := a.FileMaxBackups - 1; i > 0; i-- {
+		src := fmt.Sprintf("%s.%d", a.FilePath, i)
+		if _, err := os.Stat(src); err == nil {
+			if err := os.Rename(src, fmt.Sprintf("%s.%d", a.FilePath, i+1)); err != nil {
+				return fmt.Errorf("rotate file err: %s", err.Error())
+			}
+		}
+	}
+	if err := os.Rename(a.FilePath, a.FilePath+".1"); err != nil {
+		return fmt.Errorf("rotate file err: %s", err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+/**
+ * Created by GoLand.
+ * @author: clyde
+ * @date: 2021/11/15 下午2:00
+ * @note:
+ */
+
+package elastalert
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFileAlerter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "alerts", "matches.jsonl")
+
+	rl := &RuleBase{Name: "errors", Index: "logs-*", Alert: []string{"debug", "file"}}
+	rl.SetSettings(map[string]interface{}{
+		"file_path":        path,
+		"file_max_size":    "100",
+		"file_max_backups": 2,
+	})
+	alerters, err := NewAlerters(&Config{}, rl)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	for i := 0; i < 4; i++ {
+		m := Match{Timestamp: time.Now(), QueryKey: i, Data: map[string]interface{}{"num_hits": 3}}
+		for _, a := range alerters {
+			if err := a.Alert(ctx, rl, []Match{m}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	// every line exceeds file_max_size, so each alert but the first rotates
+	for name, qk := range map[string]float64{path: 3, path + ".1": 2, path + ".2": 1} {
+		data, err := ioutil.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var rec fileRecord
+		if err := json.Unmarshal([]byte(strings.TrimSpace(string(data))), &rec); err != nil {
+			t.Fatalf("unexpected line in %s: %s", name, data)
+		}
+		if rec.Rule != "errors" || rec.QueryKey != qk || rec.Data["num_hits"] != float64(3) {
+			t.Errorf("unexpected record in %s: %+v", name, rec)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("expected at most 2 backups")
+	}
+}
@@ -0,0 +1,204 @@
+/**
+ * Created by GoLand.
+ * @author: clyde
+ * @date: 2021/11/05 下午2:20
+ * @note: email alerter sends the matches to the email list of the rule over smtp
+ */
+
+package elastalert
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"github.com/spf13/viper"
+	"mime"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func init() {
+	RegisterAlerter("email", newEmailAlerter)
+}
+
+type emailAlerter struct {
+	// Email the recipients, the email setting of the rule
+	Email []string `mapstructure:"email"`
+	Cc    []string `mapstructure:"cc"`
+	Bcc   []string `mapstructure:"bcc"`
+
+	// EmailReplyTo FromAddr SmtpHost default to the values of the config
+	EmailReplyTo []string `mapstructure:"email_reply_to"`
+	FromAddr     string   `mapstructure:"from_addr"`
+	SmtpHost     string   `mapstructure:"smtp_host"`
+
+	// SmtpPort defaults to 25, or 465 when smtp_ssl is set
+	SmtpPort int `mapstructure:"smtp_port"`
+
+	// SmtpSsl connect with tls, SmtpStarttls upgrade a plain connection with STARTTLS
+	SmtpSsl      bool `mapstructure:"smtp_ssl"`
+	SmtpStarttls bool `mapstructure:"smtp_starttls"`
+
+	// SmtpAuthFile a yaml file holding user and password for smtp auth
+	SmtpAuthFile string `mapstructure:"smtp_auth_file"`
+
+	// SmtpTimeout the timeout of the whole conversation with the server. The default is 30s
+	SmtpTimeout time.Duration `mapstructure:"smtp_timeout"`
+
+	// EmailSubject EmailBody text/template of the subject and body, overriding alert_subject and alert_text for emails
+	EmailSubject string `mapstructure:"email_subject"`
+	EmailBody    string `mapstructure:"email_body"`
+
+	user     string
+	password string
+}
+
+func newEmailAlerter(cfg *Config, rl Rule) (Alerter, error) {
+	a := &emailAlerter{
+		EmailReplyTo: cfg.EmailReplyTo,
+		FromAddr:     cfg.FromAddr,
+		SmtpHost:     cfg.SmtpHost,
+		SmtpTimeout:  30 * time.Second,
+	}
+	if err := decodeSettings(rl, a); err != nil {
+		return nil, fmt.Errorf("decode email settings err: %s", err.Error())
+	}
+
+	if len(a.Email) == 0 {
+		return nil, fmt.Errorf("email is required")
+	}
+	if a.SmtpHost == "" {
+		return nil, fmt.Errorf("smtp_host is required")
+	}
+	if a.FromAddr == "" {
+		return nil, fmt.Errorf("from_addr is required")
+	}
+	if a.SmtpPort == 0 {
+		a.SmtpPort = 25
+		if a.SmtpSsl {
+			a.SmtpPort = 465
+		}
+	}
+
+	if a.SmtpAuthFile != "" {
+		v := viper.New()
+		v.SetConfigFile(a.SmtpAuthFile)
+		v.SetConfigType("yaml")
+		if err := v.ReadInConfig(); err != nil {
+			return nil, fmt.Errorf("read smtp_auth_file err: %s", err.Error())
+		}
+		a.user = v.GetString("user")
+		a.password = v.GetString("password")
+	}
+	return a, nil
+}
+
+func (a *emailAlerter) Alert(ctx context.Context, rl Rule, matches []Match) error {
+	subject, err := AlertSubject(rl, matches)
+	if err != nil {
+		return err
+	}
+	body, err := AlertBody(rl, matches)
+	if err != nil {
+		return err
+	}
+	if a.EmailSubject != "" {
+		if subject, err = renderTemplate("email_subject", a.EmailSubject, rl, matches); err != nil {
+			return err
+		}
+	}
+	if a.EmailBody != "" {
+		if body, err = renderTemplate("email_body", a.EmailBody, rl, matches); err != nil {
+			return err
+		}
+	}
+	return a.send(ctx, a.message(subject, body))
+}
+
+// message builds the mail, bcc recipients are left out of the headers.
+func (a *emailAlerter) message(subject, body string) []byte {
+	var b strings.Builder
+	// line breaks from the matches must not start headers of their own
+	flatten := strings.NewReplacer("\r\n", " ", "\r", " ", "\n", " ")
+	header := func(k, v string) {
+		b.WriteString(k)
+		b.WriteString(": ")
+		b.WriteString(strings.TrimSpace(flatten.Replace(v)))
+		b.WriteString("\r\n")
+	}
+	header("From", a.FromAddr)
+	header("To", strings.Join(a.Email, ", "))
+	if len(a.Cc) > 0 {
+		header("Cc", strings.Join(a.Cc, ", "))
+	}
+	if len(a.EmailReplyTo) > 0 {
+		header("Reply-To", strings.Join(a.EmailReplyTo, ", "))
+	}
+	header("Subject", mime.QEncoding.Encode("utf-8", strings.TrimSpace(flatten.Replace(subject))))
+	header("Date", time.Now().Format(time.RFC1123Z))
+	header("MIME-Version", "1.0")
+	header("Content-Type", "text/plain; charset=UTF-8")
+	b.WriteString("\r\n")
+	b.WriteString(strings.Replace(body, "\n", "\r\n", -1))
+	return []byte(b.String())
+}
+
+func (a *emailAlerter) send(ctx context.Context, msg []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, a.SmtpTimeout)
+	defer cancel()
+
+	addr := net.JoinHostPort(a.SmtpHost, strconv.Itoa(a.SmtpPort))
+	dialer := &net.Dialer{}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return fmt.Errorf("dial smtp err: %s", err.Error())
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	tlsConfig := &tls.Config{ServerName: a.SmtpHost}
+	if a.SmtpSsl {
+		conn = tls.Client(conn, tlsConfig)
+	}
+
+	c, err := smtp.NewClient(conn, a.SmtpHost)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("smtp.NewClient err: %s", err.Error())
+	}
+	defer c.Close()
+
+	if a.SmtpStarttls && !a.SmtpSsl {
+		if err := c.StartTLS(tlsConfig); err != nil {
+			return fmt.Errorf("smtp starttls err: %s", err.Error())
+		}
+	}
+	if a.user != "" {
+		if err := c.Auth(smtp.PlainAuth("", a.user, a.password, a.SmtpHost)); err != nil {
+			return fmt.Errorf("smtp auth err: %s", err.Error())
+		}
+	}
+
+	if err := c.Mail(a.FromAddr); err != nil {
+		return fmt.Errorf("smtp mail err: %s", err.Error())
+	}
+	for _, to := range append(append(append([]string{}, a.Email...), a.Cc...), a.Bcc...) {
+		if err := c.Rcpt(to); err != nil {
+			return fmt.Errorf("smtp rcpt: %s err: %s", to, err.Error())
+		}
+	}
+	wc, err := c.Data()
+	if err != nil {
+		return fmt.Errorf("smtp data err: %s", err.Error())
+	}
+	if _, err := wc.Write(msg); err != nil {
+		return fmt.Errorf("write message err: %s", err.Error())
+	}
+	if err := wc.Close(); err != nil {
+		return fmt.Errorf("smtp data err: %s", err.Error())
+	}
+	return c.Quit()
+}
@@ -0,0 +1,146 @@
+/**
+ * Created by GoLand.
+ * @author: clyde
+ * @date: 2021/11/05 下午4:02
+ * @note:
+ */
+
+package elastalert
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeSMTP accepts one mail and records its recipients and data.
+type fakeSMTP struct {
+	ln    net.Listener
+	rcpts []string
+	data  string
+	done  chan struct{}
+}
+
+func newFakeSMTP(t *testing.T) *fakeSMTP {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeSMTP{ln: ln, done: make(chan struct{})}
+	go s.serve()
+	return s
+}
+
+func (s *fakeSMTP) port() int {
+	return s.ln.Addr().(*net.TCPAddr).Port
+}
+
+func (s *fakeSMTP) serve() {
+	defer close(s.done)
+	conn, err := s.ln.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+	reply("220 localhost ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+			reply("250 localhost")
+		case strings.HasPrefix(cmd, "MAIL FROM"):
+			reply("250 OK")
+		case strings.HasPrefix(cmd, "RCPT TO"):
+			s.rcpts = append(s.rcpts, strings.Trim(strings.TrimSpace(line)[len("RCPT TO:"):], "<>"))
+			reply("250 OK")
+		case cmd == "DATA":
+			reply("354 go ahead")
+			var b strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				b.WriteString(l)
+			}
+			s.data = b.String()
+			reply("250 OK")
+		case cmd == "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 not implemented")
+		}
+	}
+}
+
+func TestEmailAlerter(t *testing.T) {
+	s := newFakeSMTP(t)
+	defer s.ln.Close()
+
+	rl := &RuleBase{Name: "errors", Index: "logs-*", Alert: []string{"email"}}
+	rl.SetSettings(map[string]interface{}{
+		"email":         "ops@example.com,dev@example.com",
+		"cc":            []interface{}{"lead@example.com"},
+		"bcc":           "audit@example.com",
+		"smtp_host":     "127.0.0.1",
+		"smtp_port":     strconv.Itoa(s.port()),
+		"email_subject": "{{.Name}} on {{.QueryKey}}",
+	})
+
+	alerters, err := NewAlerters(&Config{FromAddr: "elastalert@example.com", EmailReplyTo: []string{"noreply@example.com"}}, rl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := Match{Timestamp: time.Now(), QueryKey: "web-1", Data: map[string]interface{}{"num_hits": 3}}
+	if err := alerters[0].Alert(context.Background(), rl, []Match{m}); err != nil {
+		t.Fatal(err)
+	}
+	<-s.done
+
+	want := []string{"ops@example.com", "dev@example.com", "lead@example.com", "audit@example.com"}
+	if strings.Join(s.rcpts, ",") != strings.Join(want, ",") {
+		t.Errorf("unexpected recipients: %v", s.rcpts)
+	}
+	for _, h := range []string{"Subject: errors on web-1\r\n", "Cc: lead@example.com\r\n", "Reply-To: noreply@example.com\r\n", "num_hits: 3\r\n"} {
+		if !strings.Contains(s.data, h) {
+			t.Errorf("message lacks %q:\n%s", h, s.data)
+		}
+	}
+	if strings.Contains(s.data, "audit@example.com") {
+		t.Errorf("bcc leaked into the message:\n%s", s.data)
+	}
+}
+
+func TestEmailMessageHeaders(t *testing.T) {
+	a := &emailAlerter{FromAddr: "elastalert@example.com", Email: []string{"ops@example.com\r\nBcc: evil@example.com"}}
+	msg := string(a.message("errors on web-1\r\nBcc: evil@example.com", "body"))
+	head := msg[:strings.Index(msg, "\r\n\r\n")]
+	for _, line := range strings.Split(head, "\r\n") {
+		if strings.HasPrefix(line, "Bcc:") || strings.ContainsAny(line, "\r\n") {
+			t.Errorf("header injected: %q", line)
+		}
+	}
+	if !strings.Contains(head, "Subject: errors on web-1 Bcc: evil@example.com\r\n") {
+		t.Errorf("unexpected headers:\n%s", head)
+	}
+
+	msg = string(a.message("erreurs sur l'hôte", "body"))
+	if !strings.Contains(msg, "Subject: =?utf-8?q?erreurs_sur_l'h=C3=B4te?=\r\n") {
+		t.Errorf("subject not encoded:\n%s", msg)
+	}
+}
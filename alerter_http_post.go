@@ -0,0 +1,179 @@
+/**
+ * Created by GoLand.
+ * @author: clyde
+ * @date: 2021/11/08 上午10:30
+ * @note: http_post alerter posts each match as json to http_post_url, the helpers are shared by the webhook alerters
+ */
+
+package elastalert
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+func init() {
+	RegisterAlerter("http_post", newHttpPostAlerter)
+}
+
+type httpPostAlerter struct {
+	// HttpPostUrl one or more urls to post to
+	HttpPostUrl []string `mapstructure:"http_post_url"`
+
+	// HttpPostPayload maps the keys of the payload to fields of the match, the whole match is posted when empty
+	HttpPostPayload map[string]string `mapstructure:"http_post_payload"`
+
+	// HttpPostAllValues post the whole match along with http_post_payload
+	HttpPostAllValues bool `mapstructure:"http_post_all_values"`
+
+	// HttpPostStaticPayload keys and values added to every payload
+	HttpPostStaticPayload map[string]interface{} `mapstructure:"http_post_static_payload"`
+
+	// HttpPostTemplate text/template rendering the body, replacing the payload built from the settings above
+	HttpPostTemplate string `mapstructure:"http_post_template"`
+
+	HttpPostHeaders map[string]string `mapstructure:"http_post_headers"`
+
+	// HttpPostTimeout The default is 10s
+	HttpPostTimeout time.Duration `mapstructure:"http_post_timeout"`
+
+	HttpPostProxy           string `mapstructure:"http_post_proxy"`
+	HttpPostCaCerts         string `mapstructure:"http_post_ca_certs"`
+	HttpPostIgnoreSslErrors bool   `mapstructure:"http_post_ignore_ssl_errors"`
+
+	client *http.Client
+}
+
+func newHttpPostAlerter(cfg *Config, rl Rule) (Alerter, error) {
+	a := &httpPostAlerter{HttpPostTimeout: 10 * time.Second}
+	if err := decodeSettings(rl, a); err != nil {
+		return nil, fmt.Errorf("decode http_post settings err: %s", err.Error())
+	}
+	if len(a.HttpPostUrl) == 0 {
+		return nil, fmt.Errorf("http_post_url is required")
+	}
+
+	client, err := newHttpClient(a.HttpPostTimeout, a.HttpPostCaCerts, a.HttpPostIgnoreSslErrors, a.HttpPostProxy)
+	if err != nil {
+		return nil, err
+	}
+	a.client = client
+	return a, nil
+}
+
+func (a *httpPostAlerter) Alert(ctx context.Context, rl Rule, matches []Match) error {
+	for _, m := range matches {
+		body, err := a.payload(rl, m)
+		if err != nil {
+			return err
+		}
+		for _, u := range a.HttpPostUrl {
+			if err := postJSON(ctx, a.client, u, a.HttpPostHeaders, body); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (a *httpPostAlerter) payload(rl Rule, m Match) ([]byte, error) {
+	if a.HttpPostTemplate != "" {
+		body, err := renderTemplate("http_post_template", a.HttpPostTemplate, rl, []Match{m})
+		if err != nil {
+			return nil, err
+		}
+		return []byte(body), nil
+	}
+
+	payload := make(map[string]interface{})
+	if len(a.HttpPostPayload) == 0 || a.HttpPostAllValues {
+		subject, err := AlertSubject(rl, []Match{m})
+		if err != nil {
+			return nil, err
+		}
+		text, err := AlertBody(rl, []Match{m})
+		if err != nil {
+			return nil, err
+		}
+		payload["alert_subject"] = subject
+		payload["alert_text"] = text
+		payload["rule"] = rl.GetName()
+		payload["timestamp"] = m.Timestamp
+		payload["query_key"] = m.QueryKey
+		payload["data"] = m.Data
+		payload["documents"] = m.Documents
+	}
+	for k, field := range a.HttpPostPayload {
+		payload[k], _ = matchField(m, field)
+	}
+	for k, v := range a.HttpPostStaticPayload {
+		payload[k] = v
+	}
+
+	body, err := json.Marshal(normalize(payload))
+	if err != nil {
+		return nil, fmt.Errorf("json.Marshal payload err: %s", err.Error())
+	}
+	return body, nil
+}
+
+// newHttpClient returns the client of a webhook alerter.
+func newHttpClient(timeout time.Duration, caCerts string, insecure bool, proxy string) (*http.Client, error) {
+	tr := &http.Transport{
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: insecure},
+	}
+	if caCerts != "" {
+		pem, err := ioutil.ReadFile(caCerts)
+		if err != nil {
+			return nil, fmt.Errorf("read ca certs err: %s", err.Error())
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in: %s", caCerts)
+		}
+		tr.TLSClientConfig.RootCAs = pool
+	}
+	if proxy != "" {
+		u, err := url.Parse(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("parse proxy err: %s", err.Error())
+		}
+		tr.Proxy = http.ProxyURL(u)
+	}
+	return &http.Client{Transport: tr, Timeout: timeout}, nil
+}
+
+// postJSON posts body to u, a response status other than 2xx is an error.
+func postJSON(ctx context.Context, client *http.Client, u string, headers map[string]string, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, u, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("new request err: %s", err.Error())
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("post %s err: %s", u, err.Error())
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("post %s status: %s body: %s", u, resp.Status, msg)
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	return nil
+}
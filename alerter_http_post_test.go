@@ -0,0 +1,101 @@
+/**
+ * Created by GoLand.
+ * @author: clyde
+ * @date: 2021/11/08 下午2:15
+ * @note:
+ */
+
+package elastalert
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHttpPostAlerter(t *testing.T) {
+	var got map[string]interface{}
+	var token string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token = r.Header.Get("X-Token")
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer ts.Close()
+
+	rl := &RuleBase{Name: "errors", Index: "logs-*", Alert: []string{"http_post"}}
+	rl.SetSettings(map[string]interface{}{
+		"http_post_url":            ts.URL,
+		"http_post_payload":        map[string]interface{}{"host": "host.name", "hits": "num_hits"},
+		"http_post_static_payload": map[string]interface{}{"team": "ops"},
+		"http_post_headers":        map[string]interface{}{"X-Token": "secret"},
+		"http_post_timeout":        "2s",
+	})
+
+	alerters, err := NewAlerters(&Config{}, rl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := Match{
+		Timestamp: time.Now(),
+		Documents: []map[string]interface{}{{"host": map[string]interface{}{"name": "web-1"}}},
+		Data:      map[string]interface{}{"num_hits": 3},
+	}
+	if err := alerters[0].Alert(context.Background(), rl, []Match{m}); err != nil {
+		t.Fatal(err)
+	}
+
+	if token != "secret" {
+		t.Errorf("unexpected header: %q", token)
+	}
+	if got["host"] != "web-1" || got["hits"] != float64(3) || got["team"] != "ops" || got["rule"] != nil {
+		t.Errorf("unexpected payload: %v", got)
+	}
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+	rl.Settings["http_post_url"] = failing.URL
+	alerters, err = NewAlerters(&Config{}, rl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := alerters[0].Alert(context.Background(), rl, []Match{m}); err == nil {
+		t.Errorf("expected an error on status 500")
+	}
+}
+
+func TestHttpPostAlerterKeyCase(t *testing.T) {
+	var got map[string]interface{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer ts.Close()
+
+	rl := loadTestRule(t, `name: errors
+type: any
+index: logs-*
+alert: http_post
+http_post_url: `+ts.URL+`
+http_post_payload:
+  hostName: host.name
+http_post_static_payload:
+  teamName: ops
+`)
+	alerters, err := NewAlerters(&Config{}, rl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := Match{Timestamp: time.Now(), Documents: []map[string]interface{}{{"host": map[string]interface{}{"name": "web-1"}}}}
+	if err := alerters[0].Alert(context.Background(), rl, []Match{m}); err != nil {
+		t.Fatal(err)
+	}
+	if got["hostName"] != "web-1" || got["teamName"] != "ops" {
+		t.Errorf("expected the payload keys as written, got %v", got)
+	}
+}
@@ -0,0 +1,176 @@
+/**
+ * Created by GoLand.
+ * @author: clyde
+ * @date: 2021/11/11 下午2:30
+ * @note: opsgenie alerter creates alerts with the alert api v2, settings are prefixed by opsgenie_
+ * @refer: https://docs.opsgenie.com/docs/alert-api
+ */
+
+package elastalert
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+func init() {
+	RegisterAlerter("opsgenie", newOpsgenieAlerter)
+}
+
+type opsgenieAlerter struct {
+	// Key the api key of the integration
+	Key string `mapstructure:"key"`
+
+	// ApiUrl The default is https://api.opsgenie.com, eg https://api.eu.opsgenie.com
+	ApiUrl string `mapstructure:"api_url"`
+
+	// Priority P1 to P5, or a severity mapped to them such as critical or warning. The default is P3
+	Priority string `mapstructure:"priority"`
+
+	// Recipients Teams the users and teams to notify
+	Recipients []string `mapstructure:"recipients"`
+	Teams      []string `mapstructure:"teams"`
+
+	Tags   []string `mapstructure:"tags"`
+	Entity string   `mapstructure:"entity"`
+
+	// Source The default is elastalert
+	Source string `mapstructure:"source"`
+
+	// Details maps the keys of the alert details to fields of the match
+	Details map[string]string `mapstructure:"details"`
+
+	// AutoResolve close the alert of a query_key value once the rule stops matching it
+	AutoResolve bool `mapstructure:"auto_resolve"`
+
+	// Timeout The default is 10s
+	Timeout time.Duration `mapstructure:"timeout"`
+	Proxy   string        `mapstructure:"proxy"`
+
+	client *http.Client
+	open   incidents
+}
+
+func newOpsgenieAlerter(cfg *Config, rl Rule) (Alerter, error) {
+	a := &opsgenieAlerter{
+		ApiUrl:   "https://api.opsgenie.com",
+		Priority: "P3",
+		Source:   "elastalert",
+		Timeout:  10 * time.Second,
+		open:     make(incidents),
+	}
+	if err := decodePrefixedSettings(rl, "opsgenie_", a); err != nil {
+		return nil, fmt.Errorf("decode opsgenie settings err: %s", err.Error())
+	}
+	if a.Key == "" {
+		return nil, fmt.Errorf("opsgenie_key is required")
+	}
+	if opsgeniePriority(a.Priority) == "" {
+		return nil, fmt.Errorf("opsgenie_priority: %s not supported", a.Priority)
+	}
+	a.ApiUrl = strings.TrimSuffix(a.ApiUrl, "/")
+
+	client, err := newHttpClient(a.Timeout, "", false, a.Proxy)
+	if err != nil {
+		return nil, err
+	}
+	a.client = client
+	return a, nil
+}
+
+// opsgeniePriority maps s to a priority of the alert api, "" when it can't.
+func opsgeniePriority(s string) string {
+	switch strings.ToLower(s) {
+	case "p1", "critical":
+		return "P1"
+	case "p2", "high", "error":
+		return "P2"
+	case "p3", "warning", "warn", "medium":
+		return "P3"
+	case "p4", "low":
+		return "P4"
+	case "p5", "info":
+		return "P5"
+	}
+	return ""
+}
+
+func (a *opsgenieAlerter) Alert(ctx context.Context, rl Rule, matches []Match) error {
+	for _, m := range matches {
+		details := make(map[string]interface{}, len(a.Details))
+		for k, field := range a.Details {
+			if v, ok := matchField(m, field); ok {
+				details[k] = fieldString(v)
+			}
+		}
+		var responders []map[string]string
+		for _, r := range a.Recipients {
+			responders = append(responders, map[string]string{"username": r, "type": "user"})
+		}
+		for _, t := range a.Teams {
+			responders = append(responders, map[string]string{"name": t, "type": "team"})
+		}
+
+		subject, err := AlertSubject(rl, []Match{m})
+		if err != nil {
+			return err
+		}
+		text, err := AlertBody(rl, []Match{m})
+		if err != nil {
+			return err
+		}
+
+		key := incidentKey(rl, m.QueryKey)
+		body := map[string]interface{}{
+			"message":     subject,
+			"alias":       key,
+			"description": text,
+			"priority":    opsgeniePriority(a.Priority),
+			"source":      a.Source,
+			"details":     details,
+		}
+		if len(responders) > 0 {
+			body["responders"] = responders
+		}
+		if len(a.Tags) > 0 {
+			body["tags"] = a.Tags
+		}
+		if a.Entity != "" {
+			body["entity"] = a.Entity
+		}
+
+		if err := a.post(ctx, "/v2/alerts", body); err != nil {
+			return err
+		}
+		a.open[key] = true
+	}
+	return nil
+}
+
+// Resolve closes the alerts no longer matching when opsgenie_auto_resolve is set.
+func (a *opsgenieAlerter) Resolve(ctx context.Context, rl Rule, matches []Match) error {
+	if !a.AutoResolve {
+		return nil
+	}
+	for _, key := range a.open.stale(rl, matches) {
+		path := Concat("/v2/alerts/", url.PathEscape(key), "/close?identifierType=alias")
+		if err := a.post(ctx, path, map[string]interface{}{"source": a.Source}); err != nil {
+			return err
+		}
+		delete(a.open, key)
+	}
+	return nil
+}
+
+func (a *opsgenieAlerter) post(ctx context.Context, path string, body map[string]interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("json.Marshal body err: %s", err.Error())
+	}
+	return postJSON(ctx, a.client, a.ApiUrl+path, map[string]string{"Authorization": "GenieKey " + a.Key}, data)
+}
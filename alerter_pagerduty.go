@@ -0,0 +1,162 @@
+/**
+ * Created by GoLand.
+ * @author: clyde
+ * @date: 2021/11/11 上午10:05
+ * @note: pagerduty alerter triggers incidents with the events api v2, settings are prefixed by pagerduty_
+ * @refer: https://developer.pagerduty.com/docs/events-api-v2/trigger-events/
+ */
+
+package elastalert
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+func init() {
+	RegisterAlerter("pagerduty", newPagerDutyAlerter)
+}
+
+type pagerDutyAlerter struct {
+	// RoutingKey the integration key of the service
+	RoutingKey string `mapstructure:"routing_key"`
+
+	// ApiUrl The default is https://events.pagerduty.com/v2/enqueue
+	ApiUrl string `mapstructure:"api_url"`
+
+	// Severity critical, error, warning or info, or a severity mapped to them such as high or low. The default is critical
+	Severity string `mapstructure:"severity"`
+
+	// Source The default is elastalert
+	Source    string `mapstructure:"source"`
+	Component string `mapstructure:"component"`
+	Group     string `mapstructure:"group"`
+	Class     string `mapstructure:"class"`
+
+	// CustomDetails maps the keys of the custom details to fields of the match
+	CustomDetails map[string]string `mapstructure:"custom_details"`
+
+	// AutoResolve resolve the incident of a query_key value once the rule stops matching it
+	AutoResolve bool `mapstructure:"auto_resolve"`
+
+	// Timeout The default is 10s
+	Timeout time.Duration `mapstructure:"timeout"`
+	Proxy   string        `mapstructure:"proxy"`
+
+	client *http.Client
+	open   incidents
+}
+
+func newPagerDutyAlerter(cfg *Config, rl Rule) (Alerter, error) {
+	a := &pagerDutyAlerter{
+		ApiUrl:   "https://events.pagerduty.com/v2/enqueue",
+		Severity: "critical",
+		Source:   "elastalert",
+		Timeout:  10 * time.Second,
+		open:     make(incidents),
+	}
+	if err := decodePrefixedSettings(rl, "pagerduty_", a); err != nil {
+		return nil, fmt.Errorf("decode pagerduty settings err: %s", err.Error())
+	}
+	if a.RoutingKey == "" {
+		return nil, fmt.Errorf("pagerduty_routing_key is required")
+	}
+	if pagerDutySeverity(a.Severity) == "" {
+		return nil, fmt.Errorf("pagerduty_severity: %s not supported", a.Severity)
+	}
+
+	client, err := newHttpClient(a.Timeout, "", false, a.Proxy)
+	if err != nil {
+		return nil, err
+	}
+	a.client = client
+	return a, nil
+}
+
+// pagerDutySeverity maps s to a severity of the events api, "" when it can't.
+func pagerDutySeverity(s string) string {
+	switch strings.ToLower(s) {
+	case "critical", "high":
+		return "critical"
+	case "error":
+		return "error"
+	case "warning", "warn", "medium":
+		return "warning"
+	case "info", "low":
+		return "info"
+	}
+	return ""
+}
+
+func (a *pagerDutyAlerter) Alert(ctx context.Context, rl Rule, matches []Match) error {
+	for _, m := range matches {
+		subject, err := AlertSubject(rl, []Match{m})
+		if err != nil {
+			return err
+		}
+		text, err := AlertBody(rl, []Match{m})
+		if err != nil {
+			return err
+		}
+
+		details := map[string]interface{}{
+			"information": text,
+		}
+		for k, field := range a.CustomDetails {
+			details[k], _ = matchField(m, field)
+		}
+		payload := map[string]interface{}{
+			"summary":        subject,
+			"source":         a.Source,
+			"severity":       pagerDutySeverity(a.Severity),
+			"timestamp":      m.Timestamp.Format(time.RFC3339),
+			"custom_details": details,
+		}
+		for k, v := range map[string]string{"component": a.Component, "group": a.Group, "class": a.Class} {
+			if v != "" {
+				payload[k] = v
+			}
+		}
+
+		key := incidentKey(rl, m.QueryKey)
+		if err := a.send(ctx, "trigger", key, payload); err != nil {
+			return err
+		}
+		a.open[key] = true
+	}
+	return nil
+}
+
+// Resolve resolves the incidents no longer matching when pagerduty_auto_resolve is set.
+func (a *pagerDutyAlerter) Resolve(ctx context.Context, rl Rule, matches []Match) error {
+	if !a.AutoResolve {
+		return nil
+	}
+	for _, key := range a.open.stale(rl, matches) {
+		if err := a.send(ctx, "resolve", key, nil); err != nil {
+			return err
+		}
+		delete(a.open, key)
+	}
+	return nil
+}
+
+func (a *pagerDutyAlerter) send(ctx context.Context, action, key string, payload map[string]interface{}) error {
+	event := map[string]interface{}{
+		"routing_key":  a.RoutingKey,
+		"event_action": action,
+		"dedup_key":    key,
+	}
+	if payload != nil {
+		event["payload"] = payload
+	}
+	body, err := json.Marshal(normalize(event))
+	if err != nil {
+		return fmt.Errorf("json.Marshal event err: %s", err.Error())
+	}
+	return postJSON(ctx, a.client, a.ApiUrl, nil, body)
+}
@@ -0,0 +1,96 @@
+/**
+ * Created by GoLand.
+ * @author: clyde
+ * @date: 2021/11/11 下午4:20
+ * @note:
+ */
+
+package elastalert
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type stubRequest struct {
+	path string
+	auth string
+	body map[string]interface{}
+}
+
+func newStubServer(requests *[]stubRequest) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := stubRequest{path: r.URL.RequestURI(), auth: r.Header.Get("Authorization")}
+		json.NewDecoder(r.Body).Decode(&req.body)
+		*requests = append(*requests, req)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+}
+
+func TestPagerDutyAlerter(t *testing.T) {
+	var requests []stubRequest
+	ts := newStubServer(&requests)
+	defer ts.Close()
+
+	rl := &RuleBase{Name: "errors", Index: "logs-*", Alert: []string{"pagerduty", "opsgenie"}}
+	rl.SetSettings(map[string]interface{}{
+		"pagerduty_routing_key":    "key",
+		"pagerduty_api_url":        ts.URL + "/v2/enqueue",
+		"pagerduty_severity":       "warning",
+		"pagerduty_custom_details": map[string]interface{}{"hits": "num_hits"},
+		"pagerduty_auto_resolve":   true,
+		"opsgenie_key":             "genie",
+		"opsgenie_api_url":         ts.URL,
+		"opsgenie_priority":        "critical",
+		"opsgenie_auto_resolve":    true,
+	})
+	alerters, err := NewAlerters(&Config{}, rl)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	m := Match{Timestamp: time.Now(), QueryKey: "web-1", Data: map[string]interface{}{"num_hits": 3}}
+	for _, a := range alerters {
+		if err := a.Alert(ctx, rl, []Match{m}); err != nil {
+			t.Fatal(err)
+		}
+		// still matching, nothing to resolve
+		if err := a.(Resolver).Resolve(ctx, rl, []Match{m}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(requests) != 2 {
+		t.Fatalf("expected 2 requests, got %d", len(requests))
+	}
+	pd := requests[0].body
+	payload := pd["payload"].(map[string]interface{})
+	if pd["event_action"] != "trigger" || pd["dedup_key"] != "errors:web-1" || payload["severity"] != "warning" ||
+		payload["custom_details"].(map[string]interface{})["hits"] != float64(3) {
+		t.Errorf("unexpected pagerduty event: %v", pd)
+	}
+	og := requests[1]
+	if og.path != "/v2/alerts" || og.auth != "GenieKey genie" || og.body["alias"] != "errors:web-1" || og.body["priority"] != "P1" {
+		t.Errorf("unexpected opsgenie request: %+v", og)
+	}
+
+	requests = nil
+	for _, a := range alerters {
+		if err := a.(Resolver).Resolve(ctx, rl, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(requests) != 2 {
+		t.Fatalf("expected 2 requests, got %d", len(requests))
+	}
+	if requests[0].body["event_action"] != "resolve" || requests[0].body["dedup_key"] != "errors:web-1" {
+		t.Errorf("unexpected pagerduty event: %v", requests[0].body)
+	}
+	if requests[1].path != "/v2/alerts/errors:web-1/close?identifierType=alias" {
+		t.Errorf("unexpected opsgenie request: %+v", requests[1])
+	}
+}
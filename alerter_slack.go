@@ -0,0 +1,191 @@
+/**
+ * Created by GoLand.
+ * @author: clyde
+ * @date: 2021/11/09 上午10:40
+ * @note: slack and mattermost alerters post to incoming webhooks, both take the same settings prefixed by slack_ or mattermost_
+ * eg slack_webhook_url, mattermost_channel_override
+ */
+
+package elastalert
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+func init() {
+	RegisterAlerter("slack", func(cfg *Config, rl Rule) (Alerter, error) {
+		return newChatAlerter(rl, "slack_")
+	})
+	RegisterAlerter("mattermost", func(cfg *Config, rl Rule) (Alerter, error) {
+		return newChatAlerter(rl, "mattermost_")
+	})
+}
+
+// chatField an attachment field, value is looked up in the match.
+type chatField struct {
+	Title string `mapstructure:"title"`
+	Value string `mapstructure:"value"`
+	Short bool   `mapstructure:"short"`
+}
+
+type chatAlerter struct {
+	prefix string
+
+	// WebhookUrl one or more incoming webhook urls
+	WebhookUrl []string `mapstructure:"webhook_url"`
+
+	// UsernameOverride The default is elastalert
+	UsernameOverride string `mapstructure:"username_override"`
+
+	// ChannelOverride post to these channels instead of the default channel of the webhook
+	ChannelOverride []string `mapstructure:"channel_override"`
+
+	EmojiOverride   string `mapstructure:"emoji_override"`
+	IconUrlOverride string `mapstructure:"icon_url_override"`
+
+	// MsgColor good, warning, danger, a severity such as critical, error or info, or a hex colour. The default is danger
+	MsgColor string `mapstructure:"msg_color"`
+
+	// Title TitleLink of the attachment, the title defaults to the alert subject
+	Title     string `mapstructure:"title"`
+	TitleLink string `mapstructure:"title_link"`
+
+	// TextString text/template of the message text above the attachment
+	TextString string `mapstructure:"text_string"`
+
+	// MsgPretext the pretext of the attachment
+	MsgPretext string `mapstructure:"msg_pretext"`
+
+	AlertFields []chatField `mapstructure:"alert_fields"`
+
+	// Timeout The default is 10s
+	Timeout         time.Duration `mapstructure:"timeout"`
+	Proxy           string        `mapstructure:"proxy"`
+	CaCerts         string        `mapstructure:"ca_certs"`
+	IgnoreSslErrors bool          `mapstructure:"ignore_ssl_errors"`
+
+	client *http.Client
+}
+
+func newChatAlerter(rl Rule, prefix string) (Alerter, error) {
+	a := &chatAlerter{
+		prefix:           prefix,
+		UsernameOverride: "elastalert",
+		MsgColor:         "danger",
+		Timeout:          10 * time.Second,
+	}
+	if err := decodePrefixedSettings(rl, prefix, a); err != nil {
+		return nil, fmt.Errorf("decode %s settings err: %s", strings.TrimSuffix(prefix, "_"), err.Error())
+	}
+	if len(a.WebhookUrl) == 0 {
+		return nil, fmt.Errorf("%swebhook_url is required", prefix)
+	}
+
+	client, err := newHttpClient(a.Timeout, a.CaCerts, a.IgnoreSslErrors, a.Proxy)
+	if err != nil {
+		return nil, err
+	}
+	a.client = client
+	return a, nil
+}
+
+func (a *chatAlerter) Alert(ctx context.Context, rl Rule, matches []Match) error {
+	payload, err := a.payload(rl, matches)
+	if err != nil {
+		return err
+	}
+
+	channels := a.ChannelOverride
+	if len(channels) == 0 {
+		channels = []string{""}
+	}
+	for _, u := range a.WebhookUrl {
+		for _, channel := range channels {
+			if channel != "" {
+				payload["channel"] = channel
+			}
+			body, err := json.Marshal(payload)
+			if err != nil {
+				return fmt.Errorf("json.Marshal payload err: %s", err.Error())
+			}
+			if err := postJSON(ctx, a.client, u, nil, body); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (a *chatAlerter) payload(rl Rule, matches []Match) (map[string]interface{}, error) {
+	title := a.Title
+	if title == "" {
+		subject, err := AlertSubject(rl, matches)
+		if err != nil {
+			return nil, err
+		}
+		title = subject
+	}
+	text, err := AlertBody(rl, matches)
+	if err != nil {
+		return nil, err
+	}
+	attachment := map[string]interface{}{
+		"color":    severityColor(a.MsgColor),
+		"title":    title,
+		"text":     text,
+		"fallback": title,
+	}
+	if a.TitleLink != "" {
+		attachment["title_link"] = a.TitleLink
+	}
+	if a.MsgPretext != "" {
+		attachment["pretext"] = a.MsgPretext
+	}
+	if len(a.AlertFields) > 0 && len(matches) > 0 {
+		fields := make([]map[string]interface{}, 0, len(a.AlertFields))
+		for _, f := range a.AlertFields {
+			value := missingValue
+			if v, ok := matchField(matches[0], f.Value); ok {
+				value = fieldString(v)
+			}
+			fields = append(fields, map[string]interface{}{"title": f.Title, "value": value, "short": f.Short})
+		}
+		attachment["fields"] = fields
+	}
+
+	payload := map[string]interface{}{
+		"username":    a.UsernameOverride,
+		"attachments": []interface{}{attachment},
+	}
+	if a.TextString != "" {
+		text, err := renderTemplate(a.prefix+"text_string", a.TextString, rl, matches)
+		if err != nil {
+			return nil, err
+		}
+		payload["text"] = text
+	}
+	if a.IconUrlOverride != "" {
+		payload["icon_url"] = a.IconUrlOverride
+	} else if a.EmojiOverride != "" {
+		payload["icon_emoji"] = a.EmojiOverride
+	}
+	return payload, nil
+}
+
+// severityColor returns the attachment colour of a severity, other values are used as they are.
+func severityColor(s string) string {
+	switch strings.ToLower(s) {
+	case "critical", "high", "error", "danger":
+		return "#d00000"
+	case "warning", "warn", "medium":
+		return "#daa038"
+	case "info", "low", "ok", "good":
+		return "#36a64f"
+	}
+	return s
+}
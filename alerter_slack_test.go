@@ -0,0 +1,66 @@
+/**
+ * Created by GoLand.
+ * @author: clyde
+ * @date: 2021/11/09 下午3:05
+ * @note:
+ */
+
+package elastalert
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestChatAlerters(t *testing.T) {
+	var got []map[string]interface{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&payload)
+		got = append(got, payload)
+	}))
+	defer ts.Close()
+
+	rl := &RuleBase{Name: "errors", Index: "logs-*", Alert: []string{"slack", "mattermost"}}
+	rl.SetSettings(map[string]interface{}{
+		"slack_webhook_url":      ts.URL,
+		"slack_channel_override": []interface{}{"#ops", "#dev"},
+		"slack_msg_color":        "warning",
+		"slack_alert_fields": []interface{}{
+			map[interface{}]interface{}{"title": "Host", "value": "host", "short": true},
+		},
+		"mattermost_webhook_url":       ts.URL,
+		"mattermost_username_override": "bot",
+		"mattermost_text_string":       "{{.Name}} fired",
+	})
+
+	alerters, err := NewAlerters(&Config{}, rl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := Match{Timestamp: time.Now(), Documents: []map[string]interface{}{{"host": "web-1"}}}
+	for _, a := range alerters {
+		if err := a.Alert(context.Background(), rl, []Match{m}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(got) != 3 {
+		t.Fatalf("expected 3 posts, got %d", len(got))
+	}
+	if got[0]["channel"] != "#ops" || got[1]["channel"] != "#dev" || got[0]["username"] != "elastalert" {
+		t.Errorf("unexpected slack payloads: %v", got[:2])
+	}
+	attachment := got[0]["attachments"].([]interface{})[0].(map[string]interface{})
+	field := attachment["fields"].([]interface{})[0].(map[string]interface{})
+	if attachment["color"] != "#daa038" || field["title"] != "Host" || field["value"] != "web-1" {
+		t.Errorf("unexpected attachment: %v", attachment)
+	}
+	if got[2]["username"] != "bot" || got[2]["text"] != "errors fired" || got[2]["channel"] != nil {
+		t.Errorf("unexpected mattermost payload: %v", got[2])
+	}
+}
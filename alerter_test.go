@@ -0,0 +1,33 @@
+/**
+ * Created by GoLand.
+ * @author: clyde
+ * @date: 2021/11/05 下午4:20
+ * @note:
+ */
+
+package elastalert
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDecodeSettings(t *testing.T) {
+	var out struct {
+		Hosts   []string      `mapstructure:"hosts"`
+		Email   []string      `mapstructure:"email"`
+		Timeout time.Duration `mapstructure:"timeout"`
+	}
+	err := decodeMap(map[string]interface{}{
+		"hosts":   "http://am:9093/api?a=1,2",
+		"email":   []interface{}{"Ops, Team <ops@example.com>"},
+		"timeout": "5s",
+	}, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(out.Hosts, []string{"http://am:9093/api?a=1,2"}) || len(out.Email) != 1 || out.Timeout != 5*time.Second {
+		t.Errorf("unexpected settings: %+v", out)
+	}
+}
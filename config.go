@@ -10,11 +10,21 @@ package elastalert
 import (
 	"fmt"
 	"github.com/spf13/viper"
+	"github.com/xhit/go-str2duration/v2"
 	"log"
+	"time"
 )
 
 type DurationStr string // eg "30s" "1h"
 
+// Duration parses d, an empty string is treated as zero.
+func (d DurationStr) Duration() (time.Duration, error) {
+	if d == "" {
+		return 0, nil
+	}
+	return str2duration.ParseDuration(string(d))
+}
+
 type Config struct {
 	// EsUrl base URL of form http://ipaddr:port with no trailing slash
 	EsUrl string `mapstructure:"es_url"`
@@ -85,7 +95,7 @@ type Config struct {
 	// OldQueryLimit The maximum time between queries for ElastAlert to start at the most recently run query. The default is one week.
 	OldQueryLimit DurationStr `mapstructure:"old_query_limit"`
 
-	// AlertTimeLimit the retry window for failed alerts.
+	// AlertTimeLimit the retry window for failed alerts, they are abandoned once older. The default is two days
 	AlertTimeLimit DurationStr `mapstructure:"alert_time_limit"`
 
 	// DisableRulesOnError  This defaults to True
@@ -105,7 +115,7 @@ type Config struct {
 	ReplaceDotsInFieldNames bool `mapstructure:"replace_dots_in_field_names"`
 
 	// StringMultiFieldName If set, the suffix to use for the subfield for string multi-fields in Elasticsearch.
-	// The default value is .raw for Elasticsearch 2 and .keyword for Elasticsearch 5
+	// The default value is .keyword, use .raw for Elasticsearch 2
 	StringMultiFieldName string `mapstructure:"string_multi_field_name"`
 
 	AddMetadataAlert bool `mapstructure:"add_metadata_alert"`
@@ -136,8 +146,11 @@ func NewConfig() *Config {
 	v.SetDefault("rules_loader", "FileRulesLoader")
 	v.SetDefault("scan_subdirectories", true)
 	v.SetDefault("max_query_size", 10000)
+	v.SetDefault("scroll_keepalive", "30s")
 	v.SetDefault("max_aggregation", 10000)
 	v.SetDefault("old_query_limit", "7d")
+	v.SetDefault("alert_time_limit", "2d")
+	v.SetDefault("string_multi_field_name", ".keyword")
 	v.SetDefault("disable_rules_on_error", true)
 
 	if err := v.ReadInConfig(); err != nil {
@@ -25,13 +25,23 @@ type ElasticAlerter struct {
 	rulesLoader RulesLoader
 	startTime   time.Time
 	endTime     time.Time
+
+	ruleEndTimes map[string]time.Time // end of the last successful query window, by rule name
+	ruleAlerters map[string][]Alerter // alerters built from the alert setting, by rule name
+	silenceCache map[string]*Silence  // the last silence by silence key
+
+	aggregates map[string]*pendingAggregate // matches held by aggregation, by rule name and aggregation_key value
 }
 
 func NewElasticAlerter(cfg *Config) *ElasticAlerter {
 	e := &ElasticAlerter{
-		cfg:       cfg,
-		startTime: time.Now(),
-		endTime:   time.Now(),
+		cfg:          cfg,
+		startTime:    time.Now(),
+		endTime:      time.Now(),
+		ruleEndTimes: make(map[string]time.Time),
+		ruleAlerters: make(map[string][]Alerter),
+		silenceCache: make(map[string]*Silence),
+		aggregates:   make(map[string]*pendingAggregate),
 	}
 	e.init()
 
@@ -40,6 +50,7 @@ func NewElasticAlerter(cfg *Config) *ElasticAlerter {
 
 func (e *ElasticAlerter) init() {
 	e.initEsClient()
+	e.initWriteback(context.Background())
 	e.initRulesLoader()
 }
 
@@ -103,7 +114,7 @@ func (e *ElasticAlerter) initEsClient() {
 func (e *ElasticAlerter) initRulesLoader() {
 	switch e.cfg.RulesLoader {
 	case "FileRulesLoader":
-		e.rulesLoader = NewFileRulesLoader(e.cfg.RulesFolder, SetDescend(e.cfg.ScanSubdirectories))
+		e.rulesLoader = NewFileRulesLoader(e.cfg.RulesFolder, SetDescend(e.cfg.ScanSubdirectories), SetConfig(e.cfg))
 	default:
 		log.Fatalf("rules loader: %s not supported", e.cfg.RulesLoader)
 	}
@@ -128,92 +139,85 @@ func (e *ElasticAlerter) Run(ctx context.Context) {
 
 		case <-ticker.C:
 			log.Println("tick...")
+			e.sendDueAggregates(ctx)
+			e.sendPendingAlerts(ctx)
 			for _, rule := range e.rulesLoader.Load() {
-				rule.SetInitialStartTime(e.startTime)
-
-				switch rule.GetType() {
-				case "cardinality":
-					cardinality, ok := rule.(RuleCardinality)
-					if ok {
-						e.runCardinality(ctx, cardinality)
-					}
-
-				case "change":
-					change, ok := rule.(RuleChange)
-					if ok {
-						e.runChange(ctx, change)
-					}
-
-				case "frequency":
-					frequency, ok := rule.(RuleFrequency)
-					if ok {
-						e.runFrequency(ctx, frequency)
-					}
-
-				case "new_term":
-					newTerm, ok := rule.(RuleNewTerm)
-					if ok {
-						e.runNewTerm(ctx, newTerm)
-					}
-
-				case "percentage_match":
-					percentageMatch, ok := rule.(RulePercentageMatch)
-					if ok {
-						e.runPercentageMatch(ctx, percentageMatch)
-					}
-
-				case "metric_aggregation":
-					metricAggregation, ok := rule.(RuleMetricAggregation)
-					if ok {
-						e.runMetricAggregation(ctx, metricAggregation)
-					}
-
-				case "spike_aggregation":
-					spikeAggregation, ok := rule.(RuleSpikeAggregation)
-					if ok {
-						e.runSpikeAggregation(ctx, spikeAggregation)
-					}
-
-				case "spike":
-					spike, ok := rule.(RuleSpike)
-					if ok {
-						e.runSpike(ctx, spike)
-					}
-				}
-
+				e.runRule(ctx, rule)
 			}
 		}
 	}
 }
 
-func (e *ElasticAlerter) runCardinality(ctx context.Context, rl RuleCardinality) {
-	log.Println("runCardinality")
-}
+// runRule evaluates rl over its query window, alerts on the matches and records the run.
+func (e *ElasticAlerter) runRule(ctx context.Context, rl Rule) {
+	rl.SetInitialStartTime(e.startTime)
 
-func (e *ElasticAlerter) runChange(ctx context.Context, rl RuleChange) {
-	log.Println("runChange")
-}
-
-func (e *ElasticAlerter) runFrequency(ctx context.Context, rl RuleFrequency) {
-	log.Println("runFrequency")
-}
+	w, err := e.getQueryWindow(ctx, rl)
+	if err != nil {
+		log.Printf("rule: %s query window err: %s", rl.GetName(), err.Error())
+		return
+	}
 
-func (e *ElasticAlerter) runNewTerm(ctx context.Context, rl RuleNewTerm) {
-	log.Println("runNewTerm")
-}
+	rt, ok := LookupRuleType(rl.GetType())
+	if !ok {
+		log.Printf("rule: %s unsupported type: %s", rl.GetName(), rl.GetType())
+		return
+	}
 
-func (e *ElasticAlerter) runPercentageMatch(ctx context.Context, rl RulePercentageMatch) {
-	log.Println("runPercentageMatch")
+	begin := time.Now()
+	matches, err := rt.Engine.Evaluate(ctx, rl, w)
+	if err != nil {
+		log.Printf("run rule: %s err: %s", rl.GetName(), err.Error())
+		return
+	}
+	e.handleMatches(ctx, rl, matches)
+	e.ruleEndTimes[rl.GetName()] = w.End
+	e.writeStatus(ctx, rl, w, len(matches), time.Since(begin))
 }
 
-func (e *ElasticAlerter) runMetricAggregation(ctx context.Context, rl RuleMetricAggregation) {
-	log.Println("runMetricAggregation")
-}
+// handleMatches is called with the matches of each rule run, each match not silenced by realert is sent
+// by every alerter of the rule, or held for the aggregated alert with aggregation, then the alerters
+// implementing Resolver are given all the matches.
+func (e *ElasticAlerter) handleMatches(ctx context.Context, rl Rule, matches []Match) {
+	agg, err := rl.GetAlertAggregation()
+	if err != nil {
+		log.Printf("rule: %s aggregation err: %s", rl.GetName(), err.Error())
+	}
+	for _, m := range matches {
+		log.Printf("rule: %s matched at: %s query_key: %v data: %v", rl.GetName(), m.Timestamp.Format(time.RFC3339), m.QueryKey, m.Data)
+		if key := silenceKey(rl, m.silenceValue()); e.silenced(ctx, rl, key, time.Now()) {
+			log.Printf("rule: %s ignoring match for silenced: %s", rl.GetName(), key)
+			continue
+		}
+		if agg != nil {
+			e.aggregate(ctx, rl, agg, m)
+			continue
+		}
+		e.alert(ctx, rl, []Match{m})
+	}
 
-func (e *ElasticAlerter) runSpikeAggregation(ctx context.Context, rl RuleSpikeAggregation) {
-	log.Println("runSpikeAggregation")
+	alerters, err := e.getAlerters(rl)
+	if err != nil {
+		return
+	}
+	for i, a := range alerters {
+		if r, ok := a.(Resolver); ok {
+			if err := r.Resolve(ctx, rl, matches); err != nil {
+				log.Printf("rule: %s resolve: %s err: %s", rl.GetName(), rl.GetAlert()[i], err.Error())
+			}
+		}
+	}
 }
 
-func (e *ElasticAlerter) runSpike(ctx context.Context, rl RuleSpike) {
-	log.Println("runSpike")
+// getAlerters returns the alerters of rl, they are built on first use.
+func (e *ElasticAlerter) getAlerters(rl Rule) ([]Alerter, error) {
+	if alerters, ok := e.ruleAlerters[rl.GetName()]; ok {
+		return alerters, nil
+	}
+	alerters, err := NewAlerters(e.cfg, rl)
+	if err != nil {
+		return nil, err
+	}
+	e.ruleAlerters[rl.GetName()] = alerters
+	return alerters, nil
 }
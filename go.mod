@@ -3,7 +3,9 @@ module github.com/magiclyde/go-elastalert
 go 1.14
 
 require (
+	github.com/mitchellh/mapstructure v1.1.2
 	github.com/olivere/elastic/v7 v7.0.29
 	github.com/spf13/viper v1.7.1
 	github.com/xhit/go-str2duration/v2 v2.0.0
+	gopkg.in/yaml.v2 v2.2.8
 )
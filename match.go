@@ -0,0 +1,76 @@
+/**
+ * Created by GoLand.
+ * @author: clyde
+ * @date: 2021/11/04 上午10:05
+ * @note: the matches produced by rule engines and consumed by alerters
+ */
+
+package elastalert
+
+import (
+	"context"
+	"github.com/olivere/elastic/v7"
+	"time"
+)
+
+// Match is one finding of a rule.
+type Match struct {
+	// Timestamp the time of the matched event, or of the bucket or window for the aggregation rules
+	Timestamp time.Time `json:"timestamp"`
+
+	// QueryKey the value of the query_key field, nil when the rule has no query_key
+	QueryKey interface{} `json:"query_key,omitempty"`
+
+	// Documents the matched documents, empty for the rules evaluating aggregations
+	Documents []map[string]interface{} `json:"documents,omitempty"`
+
+	// Data rule specific values, eg num_hits for frequency or percentage for percentage_match
+	Data map[string]interface{} `json:"data,omitempty"`
+
+	// SilenceKey realert silences the match by this value instead of QueryKey when set, eg the term of new_term
+	SilenceKey interface{} `json:"-"`
+}
+
+// silenceValue returns the value realert silences m by.
+func (m Match) silenceValue() interface{} {
+	if m.SilenceKey != nil {
+		return m.SilenceKey
+	}
+	return m.QueryKey
+}
+
+// QueryWindow is the range a rule is evaluated over on one tick, along with what is needed to query it.
+type QueryWindow struct {
+	Start  time.Time
+	End    time.Time
+	Client *elastic.Client
+	Config *Config
+
+	// Hits the number of documents hit by the queries of the run
+	Hits int64
+}
+
+// RuleEngine evaluates a rule over a query window.
+type RuleEngine interface {
+	Evaluate(ctx context.Context, rl Rule, w *QueryWindow) ([]Match, error)
+}
+
+// RuleEngineFunc adapts a function to a RuleEngine.
+type RuleEngineFunc func(ctx context.Context, rl Rule, w *QueryWindow) ([]Match, error)
+
+func (f RuleEngineFunc) Evaluate(ctx context.Context, rl Rule, w *QueryWindow) ([]Match, error) {
+	return f(ctx, rl, w)
+}
+
+// docMatch returns the match of a single document.
+func docMatch(ts time.Time, doc map[string]interface{}, queryKey string, data map[string]interface{}) Match {
+	m := Match{
+		Timestamp: ts,
+		Documents: []map[string]interface{}{doc},
+		Data:      data,
+	}
+	if queryKey != "" {
+		m.QueryKey, _ = LookupField(doc, queryKey)
+	}
+	return m
+}
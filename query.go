@@ -0,0 +1,276 @@
+/**
+ * Created by GoLand.
+ * @author: clyde
+ * @date: 2021/10/22 上午10:12
+ * @note: helpers shared by the rule types to query elasticsearch
+ */
+
+package elastalert
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/olivere/elastic/v7"
+	"io"
+	"log"
+	"time"
+)
+
+// rawQuery is a filter clause taken as is from a rule file, eg {"term": {"status": "error"}}
+type rawQuery map[string]interface{}
+
+func (q rawQuery) Source() (interface{}, error) {
+	return map[string]interface{}(q), nil
+}
+
+// normalize converts the map[interface{}]interface{} produced by the yaml decoder
+// into map[string]interface{}, so the value can be marshaled to json.
+func normalize(v interface{}) interface{} {
+	switch x := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(x))
+		for k, val := range x {
+			m[fmt.Sprint(k)] = normalize(val)
+		}
+		return m
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(x))
+		for k, val := range x {
+			m[k] = normalize(val)
+		}
+		return m
+	case []interface{}:
+		s := make([]interface{}, len(x))
+		for i, val := range x {
+			s[i] = normalize(val)
+		}
+		return s
+	default:
+		return v
+	}
+}
+
+// ruleFilters returns the filter clauses of rl.
+func ruleFilters(rl Rule) []elastic.Query {
+	return filterClauses(rl.GetFilter())
+}
+
+// filterClauses converts a filter taken from a rule file to queries, the filter may be a list of clauses or a single one.
+func filterClauses(filter interface{}) []elastic.Query {
+	var clauses []interface{}
+	switch f := normalize(filter).(type) {
+	case []interface{}:
+		clauses = f
+	case map[string]interface{}:
+		clauses = []interface{}{f}
+	}
+
+	filters := make([]elastic.Query, 0, len(clauses))
+	for _, c := range clauses {
+		m, ok := c.(map[string]interface{})
+		if !ok || len(m) == 0 {
+			continue
+		}
+		// the legacy syntax wraps a clause in "query", eg {"query": {"query_string": {...}}}
+		if inner, ok := m["query"].(map[string]interface{}); ok && len(m) == 1 {
+			m = inner
+		}
+		filters = append(filters, rawQuery(m))
+	}
+	return filters
+}
+
+// BuildQuery returns a query matching the documents of rl whose timestamp is in (start, end]
+func BuildQuery(rl Rule, start, end time.Time) *elastic.BoolQuery {
+	q := elastic.NewBoolQuery().Filter(
+		elastic.NewRangeQuery(rl.GetTimestampField()).
+			Gt(toMillis(start)).
+			Lte(toMillis(end)).
+			Format("epoch_millis"),
+	)
+	return q.Filter(ruleFilters(rl)...)
+}
+
+func toMillis(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+func fromMillis(ms int64) time.Time {
+	return time.Unix(0, ms*int64(time.Millisecond))
+}
+
+// getQueryWindow returns the range rl should be queried over on this tick. A rule continues from
+// where its previous run ended, as recorded in memory or else in the writeback index, no further back
+// than old_query_limit. Without a previous run it looks back buffer_time or starts at the initial start time.
+func (e *ElasticAlerter) getQueryWindow(ctx context.Context, rl Rule) (*QueryWindow, error) {
+	w := &QueryWindow{
+		End:    time.Now(),
+		Client: e.esClient,
+		Config: e.cfg,
+	}
+	last, ok := e.ruleEndTimes[rl.GetName()]
+	if !ok {
+		var err error
+		if last, ok, err = e.lastEndTime(ctx, rl); err != nil {
+			log.Printf("rule: %s last end time err: %s", rl.GetName(), err.Error())
+		}
+	}
+	if ok {
+		limit, err := e.cfg.OldQueryLimit.Duration()
+		if err != nil {
+			return nil, fmt.Errorf("parse old_query_limit err: %s", err.Error())
+		}
+		w.Start = last
+		if limit > 0 && w.End.Sub(last) > limit {
+			w.Start = w.End.Add(-limit)
+		}
+		return w, nil
+	}
+
+	bufferTime, err := e.cfg.BufferTime.Duration()
+	if err != nil {
+		return nil, fmt.Errorf("parse buffer_time err: %s", err.Error())
+	}
+	if bufferTime > 0 {
+		w.Start = w.End.Add(-bufferTime)
+	} else {
+		w.Start = rl.GetInitialStartTime()
+	}
+	return w, nil
+}
+
+// ScrollHits runs query against the index of rl sorted by timestamp ascending, and calls fn with each hit
+// until all pages, or max_scrolling_count pages, have been read.
+func (w *QueryWindow) ScrollHits(ctx context.Context, rl Rule, query elastic.Query, fn func(hit *elastic.SearchHit) error) error {
+	keepAlive, err := w.Config.ScrollKeepalive.Duration()
+	if err != nil {
+		return fmt.Errorf("parse scroll_keepalive err: %s", err.Error())
+	}
+	if keepAlive < time.Second {
+		keepAlive = 30 * time.Second
+	}
+
+	scroll := w.Client.Scroll(rl.GetIndex()).
+		Query(query).
+		Sort(rl.GetTimestampField(), true).
+		Size(w.Config.MaxQuerySize).
+		KeepAlive(fmt.Sprintf("%ds", int(keepAlive.Seconds())))
+	defer scroll.Clear(context.Background())
+
+	for pages := 0; w.Config.MaxScrollingCount == 0 || pages < w.Config.MaxScrollingCount; pages++ {
+		res, err := scroll.Do(ctx)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("scroll index: %s err: %s", rl.GetIndex(), err.Error())
+		}
+		w.Hits += int64(len(res.Hits.Hits))
+		for _, hit := range res.Hits.Hits {
+			if err := fn(hit); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// HitDoc decodes the source of hit, adding its _id and _index.
+func HitDoc(hit *elastic.SearchHit) (map[string]interface{}, error) {
+	doc := make(map[string]interface{})
+	if len(hit.Source) > 0 {
+		if err := json.Unmarshal(hit.Source, &doc); err != nil {
+			return nil, fmt.Errorf("decode hit: %s err: %s", hit.Id, err.Error())
+		}
+	}
+	doc["_id"] = hit.Id
+	doc["_index"] = hit.Index
+	return doc, nil
+}
+
+// ScrollDocs runs the query of rl over the window and calls fn with each document and its timestamp, in time order.
+func (w *QueryWindow) ScrollDocs(ctx context.Context, rl Rule, fn func(doc map[string]interface{}, ts time.Time)) error {
+	return w.ScrollHits(ctx, rl, BuildQuery(rl, w.Start, w.End), func(hit *elastic.SearchHit) error {
+		doc, err := HitDoc(hit)
+		if err != nil {
+			return err
+		}
+		ts, err := hitTime(hit, doc, rl.GetTimestampField())
+		if err != nil {
+			return err
+		}
+		fn(doc, ts)
+		return nil
+	})
+}
+
+// ScrolledDoc is a document of the window along with its timestamp.
+type ScrolledDoc struct {
+	Doc       map[string]interface{}
+	Timestamp time.Time
+}
+
+// CollectDocs returns every document of the window in time order. Rules keeping state across runs
+// update it from the documents only once the scroll succeeded, a failed window is queried again.
+func (w *QueryWindow) CollectDocs(ctx context.Context, rl Rule) ([]ScrolledDoc, error) {
+	var docs []ScrolledDoc
+	err := w.ScrollDocs(ctx, rl, func(doc map[string]interface{}, ts time.Time) {
+		docs = append(docs, ScrolledDoc{Doc: doc, Timestamp: ts})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return docs, nil
+}
+
+// SearchAggregations runs query against the index of rl without fetching documents and returns the aggregations.
+func (w *QueryWindow) SearchAggregations(ctx context.Context, rl Rule, query elastic.Query, aggs map[string]elastic.Aggregation) (elastic.Aggregations, error) {
+	search := w.Client.Search(rl.GetIndex()).Query(query).Size(0)
+	for name, agg := range aggs {
+		search = search.Aggregation(name, agg)
+	}
+	res, err := search.Do(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("search index: %s err: %s", rl.GetIndex(), err.Error())
+	}
+	w.Hits += res.TotalHits()
+	return res.Aggregations, nil
+}
+
+// hitTime returns the timestamp of hit, taken from the sort value of the timestamp field
+// and falling back to the timestamp field in doc.
+func hitTime(hit *elastic.SearchHit, doc map[string]interface{}, tsField string) (time.Time, error) {
+	if len(hit.Sort) > 0 {
+		switch v := hit.Sort[0].(type) {
+		case float64:
+			return fromMillis(int64(v)), nil
+		case json.Number:
+			if ms, err := v.Int64(); err == nil {
+				return fromMillis(ms), nil
+			}
+		}
+	}
+
+	v, ok := LookupField(doc, tsField)
+	if !ok {
+		return time.Time{}, fmt.Errorf("hit: %s has no field: %s", hit.Id, tsField)
+	}
+	s, ok := v.(string)
+	if !ok {
+		return time.Time{}, fmt.Errorf("hit: %s field: %s is not a string", hit.Id, tsField)
+	}
+	return time.Parse(time.RFC3339Nano, s)
+}
+
+// queryKeyValue returns the value of the query_key field in doc as a string, it is empty when the rule has no query_key.
+func queryKeyValue(doc map[string]interface{}, queryKey string) string {
+	if queryKey == "" {
+		return ""
+	}
+	v, ok := LookupField(doc, queryKey)
+	if !ok || v == nil {
+		return "_missing"
+	}
+	return fmt.Sprint(v)
+}
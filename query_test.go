@@ -0,0 +1,66 @@
+/**
+ * Created by GoLand.
+ * @author: clyde
+ * @date: 2021/11/12 上午11:05
+ * @note:
+ */
+
+package elastalert
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// scrollEs serves pages of documents to the scrolls, and aggs to the aggregation searches.
+// While fail is set, reading the pages after the first fails.
+type scrollEs struct {
+	*fakeEs
+	pages [][]string
+	aggs  string
+	fail  bool
+	next  int
+}
+
+func newScrollEs(t *testing.T, aggs string, pages ...[]string) (*scrollEs, *QueryWindow) {
+	s := &scrollEs{pages: pages, aggs: aggs}
+	page := func() string {
+		var hits []string
+		if s.next < len(s.pages) {
+			for i, doc := range s.pages[s.next] {
+				hits = append(hits, `{"_index": "logs", "_id": "`+string(rune('a'+s.next))+string(rune('a'+i))+`", "_source": `+doc+`}`)
+			}
+		}
+		s.next++
+		return `{"_scroll_id": "s1", "hits": {"total": {"value": 1}, "hits": [` + strings.Join(hits, ",") + `]}}`
+	}
+	f, client := newFakeEs(t, func(method, path string, body []byte) (int, string) {
+		switch {
+		case method == http.MethodDelete:
+			return http.StatusOK, `{}`
+		case strings.Contains(string(body), `"aggregations"`):
+			return http.StatusOK, `{"hits": {"total": {"value": 1}, "hits": []}, "aggregations": ` + s.aggs + `}`
+		case strings.HasSuffix(path, "/_search/scroll"):
+			if s.fail {
+				return http.StatusInternalServerError, `{"error": "scroll lost"}`
+			}
+			return http.StatusOK, page()
+		default:
+			s.next = 0
+			return http.StatusOK, page()
+		}
+	})
+	s.fakeEs = f
+	end := time.Now()
+	return s, &QueryWindow{Start: end.Add(-time.Minute), End: end, Client: client, Config: &Config{MaxQuerySize: 100, ScrollKeepalive: "30s", StringMultiFieldName: ".keyword"}}
+}
+
+// testDoc returns a document of the fields at ts.
+func testDoc(ts time.Time, fields string) string {
+	if fields != "" {
+		fields = ", " + fields
+	}
+	return `{"@timestamp": "` + ts.UTC().Format(time.RFC3339) + `"` + fields + `}`
+}
@@ -7,12 +7,25 @@
 
 package elastalert
 
-import "time"
+import (
+	"fmt"
+	"time"
+)
 
 type Rule interface {
 	GetName() string
 	GetType() string
 	GetIndex() string
+	GetFilter() interface{}
+	GetTimestampField() string
+	GetAlert() []string
+	GetAlertSubject() (string, []string)
+	GetAlertText() (string, []string)
+	GetAlertTextType() string
+	GetRealert() (realert time.Duration, exponential time.Duration, err error)
+	GetAlertAggregation() (*AlertAggregation, error)
+	GetSettings() map[string]interface{}
+	SetSettings(settings map[string]interface{})
 	SetInitialStartTime(t time.Time)
 	GetInitialStartTime() time.Time
 }
@@ -28,7 +41,39 @@ type RuleBase struct {
 	Alert       []string    `mapstructure:"alert"`
 	Email       []string    `mapstructure:"email"`
 
+	// AlertSubject AlertText text/template of the alert subject and text, the placeholders {0}, {1}... are replaced
+	// by the values of the fields listed in alert_subject_args and alert_text_args
+	AlertSubject     string   `mapstructure:"alert_subject"`
+	AlertSubjectArgs []string `mapstructure:"alert_subject_args"`
+	AlertText        string   `mapstructure:"alert_text"`
+	AlertTextArgs    []string `mapstructure:"alert_text_args"`
+
+	// AlertTextType alert_text_only, exclude_fields, or empty for alert_text followed by the fields of the matches
+	AlertTextType string `mapstructure:"alert_text_type"`
+
+	// Realert ignore the matches of a query_key value for this long after an alert of it, 0 disables it. The default is 1m
+	Realert DurationStr `mapstructure:"realert"`
+
+	// ExponentialRealert double realert for each alert sent within the previous realert, up to this duration
+	ExponentialRealert DurationStr `mapstructure:"exponential_realert"`
+
+	// Aggregation send the matches within this period together, a duration eg 10m, or a cron schedule
+	// eg {schedule: "0 9 * * mon-fri"}
+	Aggregation interface{} `mapstructure:"aggregation"`
+
+	// AggregationKey aggregate the matches per value of this field
+	AggregationKey string `mapstructure:"aggregation_key"`
+
+	// SummaryTableFields the fields counted in the summary table of aggregated alerts
+	SummaryTableFields []string `mapstructure:"summary_table_fields"`
+
+	// TimestampField the field holding the event time, defaults to @timestamp
+	TimestampField string `mapstructure:"timestamp_field"`
+
 	InitialStartTime time.Time `mapstructure:"-"`
+
+	// Settings all the settings of the rule file, alerters decode their own options from it
+	Settings map[string]interface{} `mapstructure:"-"`
 }
 
 func (r RuleBase) GetName() string {
@@ -43,7 +88,64 @@ func (r RuleBase) GetIndex() string {
 	return r.Index
 }
 
-func (r RuleBase) SetInitialStartTime(t time.Time) {
+func (r RuleBase) GetFilter() interface{} {
+	return r.Filter
+}
+
+func (r RuleBase) GetTimestampField() string {
+	if r.TimestampField == "" {
+		return "@timestamp"
+	}
+	return r.TimestampField
+}
+
+func (r RuleBase) GetAlert() []string {
+	return r.Alert
+}
+
+func (r RuleBase) GetAlertSubject() (string, []string) {
+	return r.AlertSubject, r.AlertSubjectArgs
+}
+
+func (r RuleBase) GetAlertText() (string, []string) {
+	return r.AlertText, r.AlertTextArgs
+}
+
+func (r RuleBase) GetAlertTextType() string {
+	return r.AlertTextType
+}
+
+// GetRealert returns realert, 1m when unset, and exponential_realert.
+func (r RuleBase) GetRealert() (time.Duration, time.Duration, error) {
+	realert := time.Minute
+	if r.Realert != "" {
+		v, err := r.Realert.Duration()
+		if err != nil {
+			return 0, 0, fmt.Errorf("parse realert err: %s", err.Error())
+		}
+		realert = v
+	}
+	exponential, err := r.ExponentialRealert.Duration()
+	if err != nil {
+		return 0, 0, fmt.Errorf("parse exponential_realert err: %s", err.Error())
+	}
+	return realert, exponential, nil
+}
+
+// GetAlertAggregation returns the parsed aggregation settings, nil when aggregation is not set.
+func (r RuleBase) GetAlertAggregation() (*AlertAggregation, error) {
+	return parseAlertAggregation(r.Aggregation, r.AggregationKey, r.SummaryTableFields)
+}
+
+func (r RuleBase) GetSettings() map[string]interface{} {
+	return r.Settings
+}
+
+func (r *RuleBase) SetSettings(settings map[string]interface{}) {
+	r.Settings = settings
+}
+
+func (r *RuleBase) SetInitialStartTime(t time.Time) {
 	r.InitialStartTime = t
 }
 
@@ -54,7 +156,9 @@ func (r RuleBase) GetInitialStartTime() time.Time {
 type RuleCardinality struct {
 	RuleBase         `mapstructure:",squash"`
 	CardinalityField string `mapstructure:"cardinality_field"` // Count the number of unique values for this field
-	MinCardinality   int    `mapstructure:"min_cardinality"`
+	MinCardinality   int    `mapstructure:"min_cardinality"`   // Alert when the number of unique values drops below this
+	MaxCardinality   int    `mapstructure:"max_cardinality"`   // Alert when the number of unique values exceeds this, 0 disables it
+	QueryKey         string `mapstructure:"query_key"`         // Unique values are counted separately for each value of query_key
 }
 
 type RuleChange struct {
@@ -62,63 +166,111 @@ type RuleChange struct {
 	CompareKey string `mapstructure:"compare_key"` // The field to look for changes in
 	IgnoreNull bool   `mapstructure:"ignore_null"` // Ignore documents without the compare_key (country_name) field
 	QueryKey   string `mapstructure:"query_key"`   // The change must occur in two documents with the same query_key
+
+	lastValues map[string]interface{} // last seen value of compare_key, by query_key value
+	lastTimes  map[string]time.Time   // time the last value was seen, by query_key value
 }
 
 type RuleFrequency struct {
 	RuleBase `mapstructure:",squash"`
+	QueryKey string `mapstructure:"query_key"` // Events are counted separately for each value of query_key
+
+	occurrences map[string][]time.Time // event times inside the current timeframe, by query_key value
 }
 
 type RuleNewTerm struct {
-	RuleBase        `mapstructure:",squash"`
-	Fields          []string    `mapstructure:"fields"`
-	TermsWindowSize DurationStr `mapstructure:"terms_window_size"`
+	RuleBase            `mapstructure:",squash"`
+	Fields              []interface{} `mapstructure:"fields"`                 // Fields to monitor, a list of fields is treated as one composite term
+	TermsWindowSize     DurationStr   `mapstructure:"terms_window_size"`      // How far back the known terms are loaded from, defaults to 30d
+	TermsSize           int           `mapstructure:"terms_size"`             // Page size of the terms aggregation used to load the known terms
+	AlertOnMissingField bool          `mapstructure:"alert_on_missing_field"` // Match documents missing one of the fields
+	UseKeywordPostfix   bool          `mapstructure:"use_keyword_postfix"`    // Load the known terms from the string_multi_field_name subfields, defaults to true
+
+	knownTerms map[string]map[string]bool // terms seen so far, by fields
 }
 
-type RulePercentageMatch struct {
-	RuleBase               `mapstructure:",squash"`
+// AggregationBase holds the settings shared by the rule types evaluating bucketed aggregations
+type AggregationBase struct {
 	BufferTime             DurationStr `mapstructure:"buffer_time"`
 	QueryKey               string      `mapstructure:"query_key"`
 	DocType                string      `mapstructure:"doc_type"`
-	MinPercentage          int         `mapstructure:"min_percentage"`
-	MaxPercentage          int         `mapstructure:"max_percentage"`
 	BucketInterval         DurationStr `mapstructure:"bucket_interval"`
 	SyncBucketInterval     bool        `mapstructure:"sync_bucket_interval"`
 	AllowBufferTimeOverlap bool        `mapstructure:"allow_buffer_time_overlap"`
 	UseRunEveryQuerySize   bool        `mapstructure:"use_run_every_query_size"`
 }
 
+type RulePercentageMatch struct {
+	RuleBase          `mapstructure:",squash"`
+	AggregationBase   `mapstructure:",squash"`
+	MatchBucketFilter interface{} `mapstructure:"match_bucket_filter"` // Documents matching this filter are counted against all documents
+	MinPercentage     float64     `mapstructure:"min_percentage"`
+	MaxPercentage     float64     `mapstructure:"max_percentage"`
+	MinDenominator    int         `mapstructure:"min_denominator"` // Buckets with fewer documents are not evaluated
+}
+
 type RuleMetricAggregation struct {
-	RuleBase               `mapstructure:",squash"`
-	BufferTime             DurationStr `mapstructure:"buffer_time"`
-	MetricAggKey           string      `mapstructure:"metric_agg_key"`
-	MetricAggType          string      `mapstructure:"metric_agg_type"`
-	QueryKey               string      `mapstructure:"query_key"`
-	DocType                string      `mapstructure:"doc_type"`
-	BucketInterval         DurationStr `mapstructure:"bucket_interval"`
-	SyncBucketInterval     bool        `mapstructure:"sync_bucket_interval"`
-	AllowBufferTimeOverlap bool        `mapstructure:"allow_buffer_time_overlap"`
-	UseRunEveryQuerySize   bool        `mapstructure:"use_run_every_query_size"`
-	MinThreshold           float64     `mapstructure:"min_threshold"`
-	MaxThreshold           float64     `mapstructure:"max_threshold"`
+	RuleBase        `mapstructure:",squash"`
+	AggregationBase `mapstructure:",squash"`
+	MetricAggKey    string   `mapstructure:"metric_agg_key"`
+	MetricAggType   string   `mapstructure:"metric_agg_type"`  // One of min, max, avg, sum, cardinality, value_count, percentiles
+	PercentileRange float64  `mapstructure:"percentile_range"` // The percentile computed when metric_agg_type is percentiles
+	MinThreshold    *float64 `mapstructure:"min_threshold"`
+	MaxThreshold    *float64 `mapstructure:"max_threshold"`
 }
 
 type RuleSpike struct {
 	RuleBase     `mapstructure:",squash"`
-	ThresholdCur int    `mapstructure:"threshold_cur"`
-	ThresholdRef int    `mapstructure:"threshold_ref"`
-	SpikeHeight  int    `mapstructure:"spike_height"`
-	SpikeType    string `mapstructure:"spike_type"`
+	ThresholdCur int     `mapstructure:"threshold_cur"` // Minimum number of events in the current window
+	ThresholdRef int     `mapstructure:"threshold_ref"` // Minimum number of events in the reference window
+	SpikeHeight  float64 `mapstructure:"spike_height"`  // Ratio between the two windows that is considered a spike
+	SpikeType    string  `mapstructure:"spike_type"`    // One of up, down or both, defaults to both
+	QueryKey     string  `mapstructure:"query_key"`     // Windows are kept separately for each value of query_key
+
+	AlertOnNewData bool `mapstructure:"alert_on_new_data"` // Match events after an empty reference window, eg a new query_key value
 }
 
 type RuleSpikeAggregation struct {
-	RuleBase      `mapstructure:",squash"`
-	BufferTime    DurationStr `mapstructure:"buffer_time"`
-	MetricAggKey  string      `mapstructure:"metric_agg_key"`
-	MetricAggType string      `mapstructure:"metric_agg_type"`
-	QueryKey      string      `mapstructure:"query_key"`
-	DocType       string      `mapstructure:"doc_type"`
-	ThresholdCur  int         `mapstructure:"threshold_cur"`
-	ThresholdRef  int         `mapstructure:"threshold_ref"`
-	SpikeHeight   int         `mapstructure:"spike_height"`
-	SpikeType     string      `mapstructure:"spike_type"`
+	RuleBase        `mapstructure:",squash"`
+	BufferTime      DurationStr `mapstructure:"buffer_time"`
+	MetricAggKey    string      `mapstructure:"metric_agg_key"`
+	MetricAggType   string      `mapstructure:"metric_agg_type"`  // One of min, max, avg, sum, cardinality, value_count, percentiles
+	PercentileRange float64     `mapstructure:"percentile_range"` // The percentile computed when metric_agg_type is percentiles
+	QueryKey        string      `mapstructure:"query_key"`
+	DocType         string      `mapstructure:"doc_type"`
+	ThresholdCur    float64     `mapstructure:"threshold_cur"` // Minimum metric value of the current window
+	ThresholdRef    float64     `mapstructure:"threshold_ref"` // Minimum metric value of the reference window
+	SpikeHeight     float64     `mapstructure:"spike_height"`
+	SpikeType       string      `mapstructure:"spike_type"`
+	AlertOnNewData  bool        `mapstructure:"alert_on_new_data"` // Match a metric after an empty reference window
+}
+
+type RuleFlatline struct {
+	RuleBase   `mapstructure:",squash"`
+	Threshold  int    `mapstructure:"threshold"`   // Match when fewer events than this occur within timeframe
+	QueryKey   string `mapstructure:"query_key"`   // Events are counted separately for each value of query_key
+	ForgetKeys bool   `mapstructure:"forget_keys"` // Stop tracking a query_key value once it has matched
+
+	knownKeys map[string]interface{} // query_key values seen so far
+}
+
+type RuleAny struct {
+	RuleBase `mapstructure:",squash"`
+}
+
+type RuleBlacklist struct {
+	RuleBase   `mapstructure:",squash"`
+	CompareKey string   `mapstructure:"compare_key"` // The field whose value is checked against blacklist
+	Blacklist  []string `mapstructure:"blacklist"`   // Values to match, "- !file /path" loads one value per line from a file, quote it within [...]
+
+	listed map[string]bool // blacklist with the files expanded
+}
+
+type RuleWhitelist struct {
+	RuleBase   `mapstructure:",squash"`
+	CompareKey string   `mapstructure:"compare_key"` // The field whose value is checked against whitelist
+	Whitelist  []string `mapstructure:"whitelist"`   // Values not to match, "- !file /path" loads one value per line from a file, quote it within [...]
+	IgnoreNull bool     `mapstructure:"ignore_null"` // Ignore documents without the compare_key field
+
+	listed map[string]bool // whitelist with the files expanded
 }
@@ -0,0 +1,191 @@
+/**
+ * Created by GoLand.
+ * @author: clyde
+ * @date: 2021/10/27 上午9:55
+ * @note: helpers shared by the rule types evaluating bucketed aggregations
+ */
+
+package elastalert
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/olivere/elastic/v7"
+	"math"
+	"time"
+)
+
+// validate checks the durations of the aggregation settings.
+func (a *AggregationBase) validate() error {
+	if err := validDuration("buffer_time", a.BufferTime, false); err != nil {
+		return err
+	}
+	return validDuration("bucket_interval", a.BucketInterval, false)
+}
+
+// queryWindow returns the range queried on this tick: buffer_time back from end, or run_every with
+// use_run_every_query_size. It does not reach back past the previous run unless allow_buffer_time_overlap
+// is set, and its start is aligned to bucket_interval when sync_bucket_interval is set.
+func (a *AggregationBase) queryWindow(cfg *Config, start, end time.Time) (time.Time, time.Time, error) {
+	size := a.BufferTime
+	if size == "" {
+		size = cfg.BufferTime
+	}
+	if a.UseRunEveryQuerySize {
+		size = cfg.RunEvery
+	}
+	d, err := size.Duration()
+	if err != nil {
+		return start, end, fmt.Errorf("parse buffer_time err: %s", err.Error())
+	}
+
+	qStart := start
+	if d > 0 {
+		qStart = end.Add(-d)
+	}
+	if !a.AllowBufferTimeOverlap && start.After(qStart) {
+		qStart = start
+	}
+
+	interval, err := a.BucketInterval.Duration()
+	if err != nil {
+		return start, end, fmt.Errorf("parse bucket_interval err: %s", err.Error())
+	}
+	if a.SyncBucketInterval && interval > 0 {
+		qStart = qStart.Truncate(interval)
+	}
+	return qStart, end, nil
+}
+
+// bucketAggregations nests aggs in a date histogram on bucket_interval, then in a terms aggregation on
+// query_key, leaving out the levels that are not configured. It returns the outermost aggregations by name.
+func (a *AggregationBase) bucketAggregations(tsField string, size int, aggs map[string]elastic.Aggregation) (map[string]elastic.Aggregation, error) {
+	interval, err := a.BucketInterval.Duration()
+	if err != nil {
+		return nil, fmt.Errorf("parse bucket_interval err: %s", err.Error())
+	}
+
+	if interval > 0 {
+		histogram := elastic.NewDateHistogramAggregation().
+			Field(tsField).
+			FixedInterval(fmt.Sprintf("%ds", int(interval.Seconds())))
+		for name, agg := range aggs {
+			histogram = histogram.SubAggregation(name, agg)
+		}
+		aggs = map[string]elastic.Aggregation{"by_time": histogram}
+	}
+
+	return keyAggregations(a.QueryKey, size, aggs), nil
+}
+
+// walkBuckets calls fn with the innermost aggregations of every bucket built by bucketAggregations, along
+// with the bucket time and query_key value. The time is end when there is no bucket_interval, the empty
+// time buckets are skipped.
+func (a *AggregationBase) walkBuckets(aggs elastic.Aggregations, end time.Time, fn func(leaf elastic.Aggregations, ts time.Time, key interface{})) {
+	walkTime := func(aggs elastic.Aggregations, key interface{}) {
+		if a.BucketInterval == "" {
+			fn(aggs, end, key)
+			return
+		}
+		histogram, ok := aggs.DateHistogram("by_time")
+		if !ok {
+			return
+		}
+		for _, bucket := range histogram.Buckets {
+			if bucket.DocCount == 0 {
+				continue
+			}
+			fn(bucket.Aggregations, fromMillis(int64(bucket.Key)), key)
+		}
+	}
+
+	walkKeys(aggs, a.QueryKey, walkTime)
+}
+
+// keyAggregations nests aggs in a terms aggregation on queryKey when it is set.
+func keyAggregations(queryKey string, size int, aggs map[string]elastic.Aggregation) map[string]elastic.Aggregation {
+	if queryKey == "" {
+		return aggs
+	}
+	terms := elastic.NewTermsAggregation().Field(queryKey).Size(size)
+	for name, agg := range aggs {
+		terms = terms.SubAggregation(name, agg)
+	}
+	return map[string]elastic.Aggregation{"by_key": terms}
+}
+
+// walkKeys calls fn with the aggregations of every query_key bucket built by keyAggregations,
+// or once with aggs and a nil key when queryKey is not set.
+func walkKeys(aggs elastic.Aggregations, queryKey string, fn func(leaf elastic.Aggregations, key interface{})) {
+	if queryKey == "" {
+		fn(aggs, nil)
+		return
+	}
+	terms, ok := aggs.Terms("by_key")
+	if !ok {
+		return
+	}
+	for _, bucket := range terms.Buckets {
+		fn(bucket.Aggregations, bucket.Key)
+	}
+}
+
+// metricAggregation returns the aggregation computing aggType on field.
+func metricAggregation(aggType, field string, percentile float64) (elastic.Aggregation, error) {
+	if field == "" {
+		return nil, fmt.Errorf("metric_agg_key is required")
+	}
+	switch aggType {
+	case "min":
+		return elastic.NewMinAggregation().Field(field), nil
+	case "max":
+		return elastic.NewMaxAggregation().Field(field), nil
+	case "avg":
+		return elastic.NewAvgAggregation().Field(field), nil
+	case "sum":
+		return elastic.NewSumAggregation().Field(field), nil
+	case "cardinality":
+		return elastic.NewCardinalityAggregation().Field(field), nil
+	case "value_count":
+		return elastic.NewValueCountAggregation().Field(field), nil
+	case "percentiles":
+		if percentile <= 0 || percentile >= 100 {
+			return nil, fmt.Errorf("percentile_range must be between 0 and 100")
+		}
+		return elastic.NewPercentilesAggregation().Field(field).Percentiles(percentile), nil
+	default:
+		return nil, fmt.Errorf("unsupported metric_agg_type: %s", aggType)
+	}
+}
+
+// metricValue returns the value of the metric aggregation name built by metricAggregation,
+// it is false when the bucket held no value.
+func metricValue(aggs elastic.Aggregations, name, aggType string) (float64, bool) {
+	if aggType == "percentiles" {
+		// decoded by hand, elastic takes a null percentile for 0
+		raw, ok := aggs[name]
+		if !ok {
+			return 0, false
+		}
+		var agg struct {
+			Values map[string]*float64 `json:"values"`
+		}
+		if err := json.Unmarshal(raw, &agg); err != nil {
+			return 0, false
+		}
+		for _, v := range agg.Values {
+			if v == nil || math.IsNaN(*v) {
+				return 0, false
+			}
+			return *v, true
+		}
+		return 0, false
+	}
+
+	// every single value metric decodes the same way
+	agg, ok := aggs.Min(name)
+	if !ok || agg.Value == nil {
+		return 0, false
+	}
+	return *agg.Value, true
+}
@@ -0,0 +1,39 @@
+/**
+ * Created by GoLand.
+ * @author: clyde
+ * @date: 2021/10/27 下午4:40
+ * @note:
+ */
+
+package elastalert
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAggregationQueryWindow(t *testing.T) {
+	cfg := &Config{BufferTime: "1h", RunEvery: "5m"}
+	end := time.Date(2021, 10, 27, 12, 7, 0, 0, time.UTC)
+	prev := end.Add(-5 * time.Minute)
+
+	cases := []struct {
+		agg   AggregationBase
+		start time.Time
+	}{
+		{AggregationBase{}, prev}, // no overlap with the previous run
+		{AggregationBase{AllowBufferTimeOverlap: true}, end.Add(-time.Hour)},
+		{AggregationBase{BufferTime: "30m", AllowBufferTimeOverlap: true}, end.Add(-30 * time.Minute)},
+		{AggregationBase{UseRunEveryQuerySize: true, AllowBufferTimeOverlap: true}, prev},
+		{AggregationBase{BucketInterval: "10m", SyncBucketInterval: true}, time.Date(2021, 10, 27, 12, 0, 0, 0, time.UTC)},
+	}
+	for i, c := range cases {
+		start, got, err := c.agg.queryWindow(cfg, prev, end)
+		if err != nil {
+			t.Fatalf("case %d: %s", i, err.Error())
+		}
+		if !start.Equal(c.start) || !got.Equal(end) {
+			t.Errorf("case %d: got [%s, %s], want [%s, %s]", i, start, got, c.start, end)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+/**
+ * Created by GoLand.
+ * @author: clyde
+ * @date: 2021/11/02 上午9:30
+ * @note: any rule matches every document returned by the filter
+ */
+
+package elastalert
+
+import (
+	"context"
+	"time"
+)
+
+func init() {
+	RegisterRuleType("any", RuleType{
+		New: func() Rule { return &RuleAny{} },
+		Engine: RuleEngineFunc(func(ctx context.Context, rl Rule, w *QueryWindow) ([]Match, error) {
+			return rl.(*RuleAny).evaluate(ctx, w)
+		}),
+	})
+}
+
+func (r *RuleAny) evaluate(ctx context.Context, w *QueryWindow) ([]Match, error) {
+	var matches []Match
+	err := w.ScrollDocs(ctx, r, func(doc map[string]interface{}, ts time.Time) {
+		matches = append(matches, docMatch(ts, doc, "", nil))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return matches, nil
+}
@@ -0,0 +1,77 @@
+/**
+ * Created by GoLand.
+ * @author: clyde
+ * @date: 2021/10/25 上午9:48
+ * @note: cardinality rule matches when the number of unique values of a field within timeframe is out of range
+ */
+
+package elastalert
+
+import (
+	"context"
+	"fmt"
+	"github.com/olivere/elastic/v7"
+)
+
+func init() {
+	RegisterRuleType("cardinality", RuleType{
+		New:      func() Rule { return &RuleCardinality{} },
+		Validate: func(rl Rule) error { return rl.(*RuleCardinality).validate() },
+		Engine: RuleEngineFunc(func(ctx context.Context, rl Rule, w *QueryWindow) ([]Match, error) {
+			return rl.(*RuleCardinality).evaluate(ctx, w)
+		}),
+	})
+}
+
+func (r *RuleCardinality) validate() error {
+	if r.CardinalityField == "" {
+		return fmt.Errorf("cardinality_field is required")
+	}
+	if r.MinCardinality <= 0 && r.MaxCardinality <= 0 {
+		return fmt.Errorf("min_cardinality or max_cardinality is required")
+	}
+	return validDuration("timeframe", r.TimeFrame, true)
+}
+
+// outOfRange reports whether n unique values violates min_cardinality or max_cardinality.
+func (r *RuleCardinality) outOfRange(n int) bool {
+	if n < r.MinCardinality {
+		return true
+	}
+	return r.MaxCardinality > 0 && n > r.MaxCardinality
+}
+
+// evaluate counts the unique values with a cardinality aggregation over the last timeframe,
+// nested in a terms aggregation on query_key when the rule has one.
+func (r *RuleCardinality) evaluate(ctx context.Context, w *QueryWindow) ([]Match, error) {
+	timeframe, err := r.TimeFrame.Duration()
+	if err != nil {
+		return nil, fmt.Errorf("parse timeframe err: %s", err.Error())
+	}
+
+	aggs := keyAggregations(r.QueryKey, w.Config.MaxQuerySize, map[string]elastic.Aggregation{
+		"cardinality": elastic.NewCardinalityAggregation().Field(r.CardinalityField),
+	})
+	res, err := w.SearchAggregations(ctx, r, BuildQuery(r, w.End.Add(-timeframe), w.End), aggs)
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []Match
+	walkKeys(res, r.QueryKey, func(leaf elastic.Aggregations, key interface{}) {
+		agg, ok := leaf.Cardinality("cardinality")
+		if !ok || agg.Value == nil {
+			return
+		}
+		n := int(*agg.Value)
+		if !r.outOfRange(n) {
+			return
+		}
+		matches = append(matches, Match{
+			Timestamp: w.End,
+			QueryKey:  key,
+			Data:      map[string]interface{}{"cardinality": n},
+		})
+	})
+	return matches, nil
+}
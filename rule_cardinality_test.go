@@ -0,0 +1,89 @@
+/**
+ * Created by GoLand.
+ * @author: clyde
+ * @date: 2021/10/25 上午10:30
+ * @note:
+ */
+
+package elastalert
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestCardinalityEvaluate(t *testing.T) {
+	bucket := func(key string, value int) string {
+		return fmt.Sprintf(`{"key": "%s", "doc_count": 10, "cardinality": {"value": %d}}`, key, value)
+	}
+
+	cases := []struct {
+		name     string
+		rl       *RuleCardinality
+		aggs     string
+		expected []string // query_key=cardinality of the matches
+	}{
+		{
+			name:     "above max_cardinality",
+			rl:       &RuleCardinality{MaxCardinality: 5},
+			aggs:     `{"cardinality": {"value": 6}}`,
+			expected: []string{"<nil>=6"},
+		},
+		{
+			name: "at max_cardinality",
+			rl:   &RuleCardinality{MaxCardinality: 5},
+			aggs: `{"cardinality": {"value": 5}}`,
+		},
+		{
+			name:     "below min_cardinality",
+			rl:       &RuleCardinality{MinCardinality: 3},
+			aggs:     `{"cardinality": {"value": 2}}`,
+			expected: []string{"<nil>=2"},
+		},
+		{
+			name: "at min_cardinality",
+			rl:   &RuleCardinality{MinCardinality: 3},
+			aggs: `{"cardinality": {"value": 3}}`,
+		},
+		{
+			name: "per query_key",
+			rl:   &RuleCardinality{MinCardinality: 2, MaxCardinality: 5, QueryKey: "host"},
+			aggs: `{"by_key": {"buckets": [` + strings.Join([]string{
+				bucket("web-1", 1),
+				bucket("web-2", 3),
+				bucket("web-3", 8),
+			}, ",") + `]}}`,
+			expected: []string{"web-1=1", "web-3=8"},
+		},
+	}
+	for _, c := range cases {
+		s, w := newScrollEs(t, c.aggs)
+		c.rl.RuleBase = RuleBase{Name: "users", Index: "logs-*", TimestampField: "@timestamp", TimeFrame: "1h"}
+		c.rl.CardinalityField = "user"
+		if err := c.rl.validate(); err != nil {
+			t.Fatalf("%s: %s", c.name, err.Error())
+		}
+		matches, err := c.rl.evaluate(context.Background(), w)
+		s.server.Close()
+		if err != nil {
+			t.Fatalf("%s: %s", c.name, err.Error())
+		}
+
+		var got []string
+		for _, m := range matches {
+			got = append(got, fmt.Sprintf("%v=%v", m.QueryKey, m.Data["cardinality"]))
+		}
+		if fmt.Sprint(got) != fmt.Sprint(c.expected) {
+			t.Errorf("%s: expected matches %v, got %v", c.name, c.expected, got)
+		}
+		body := s.find("POST", "/logs-*/_search")
+		if len(body) != 1 || !strings.Contains(string(body[0]), `"cardinality":{"field":"user"}`) {
+			t.Errorf("%s: unexpected search: %q", c.name, body)
+		}
+		if c.rl.QueryKey != "" && !strings.Contains(string(body[0]), `"terms":{"field":"host"`) {
+			t.Errorf("%s: expected a terms aggregation on query_key: %s", c.name, body[0])
+		}
+	}
+}
@@ -0,0 +1,80 @@
+/**
+ * Created by GoLand.
+ * @author: clyde
+ * @date: 2021/10/25 下午3:20
+ * @note: change rule matches when the value of compare_key changes between two documents with the same query_key
+ */
+
+package elastalert
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+func init() {
+	RegisterRuleType("change", RuleType{
+		New:      func() Rule { return &RuleChange{} },
+		Validate: func(rl Rule) error { return rl.(*RuleChange).validate() },
+		Engine: RuleEngineFunc(func(ctx context.Context, rl Rule, w *QueryWindow) ([]Match, error) {
+			return rl.(*RuleChange).evaluate(ctx, w)
+		}),
+	})
+}
+
+func (r *RuleChange) validate() error {
+	if r.CompareKey == "" {
+		return fmt.Errorf("compare_key is required")
+	}
+	return validDuration("timeframe", r.TimeFrame, false)
+}
+
+// observe records value as the latest compare_key of key seen at ts, it returns the previous value
+// and whether the value changed within timeframe. A timeframe of 0 means no limit.
+func (r *RuleChange) observe(key string, value interface{}, ts time.Time, timeframe time.Duration) (interface{}, bool) {
+	if value == nil && r.IgnoreNull {
+		return nil, false
+	}
+	if r.lastValues == nil {
+		r.lastValues = make(map[string]interface{})
+		r.lastTimes = make(map[string]time.Time)
+	}
+
+	prev, seen := r.lastValues[key]
+	prevTime := r.lastTimes[key]
+	r.lastValues[key] = value
+	r.lastTimes[key] = ts
+
+	if !seen || reflect.DeepEqual(prev, value) {
+		return prev, false
+	}
+	if timeframe > 0 && ts.Sub(prevTime) > timeframe {
+		return prev, false
+	}
+	return prev, true
+}
+
+func (r *RuleChange) evaluate(ctx context.Context, w *QueryWindow) ([]Match, error) {
+	timeframe, err := r.TimeFrame.Duration()
+	if err != nil {
+		return nil, fmt.Errorf("parse timeframe err: %s", err.Error())
+	}
+
+	docs, err := w.CollectDocs(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	var matches []Match
+	for _, d := range docs {
+		value, _ := LookupField(d.Doc, r.CompareKey)
+		if prev, changed := r.observe(queryKeyValue(d.Doc, r.QueryKey), value, d.Timestamp, timeframe); changed {
+			matches = append(matches, docMatch(d.Timestamp, d.Doc, r.QueryKey, map[string]interface{}{
+				"old_value": prev,
+				"new_value": value,
+			}))
+		}
+	}
+	return matches, nil
+}
@@ -0,0 +1,38 @@
+/**
+ * Created by GoLand.
+ * @author: clyde
+ * @date: 2021/10/25 下午4:05
+ * @note:
+ */
+
+package elastalert
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRuleChangeObserve(t *testing.T) {
+	rl := &RuleChange{IgnoreNull: true}
+	base := time.Date(2021, 10, 25, 0, 0, 0, 0, time.UTC)
+	timeframe := time.Hour
+
+	cases := []struct {
+		key     string
+		value   interface{}
+		offset  time.Duration
+		changed bool
+	}{
+		{"bob", "US", 0, false}, // first value seen for bob
+		{"bob", "US", time.Minute, false},
+		{"alice", "CN", 2 * time.Minute, false},
+		{"bob", nil, 3 * time.Minute, false}, // ignored by ignore_null
+		{"bob", "CN", 4 * time.Minute, true},
+		{"bob", "US", 2 * time.Hour, false}, // changed, but not within timeframe
+	}
+	for i, c := range cases {
+		if _, changed := rl.observe(c.key, c.value, base.Add(c.offset), timeframe); changed != c.changed {
+			t.Errorf("case %d: got changed %v, want %v", i, changed, c.changed)
+		}
+	}
+}
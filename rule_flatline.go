@@ -0,0 +1,112 @@
+/**
+ * Created by GoLand.
+ * @author: clyde
+ * @date: 2021/11/01 上午10:42
+ * @note: flatline rule matches when fewer than threshold events occur within timeframe
+ */
+
+package elastalert
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+func init() {
+	RegisterRuleType("flatline", RuleType{
+		New:      func() Rule { return &RuleFlatline{} },
+		Validate: func(rl Rule) error { return rl.(*RuleFlatline).validate() },
+		Engine: RuleEngineFunc(func(ctx context.Context, rl Rule, w *QueryWindow) ([]Match, error) {
+			return rl.(*RuleFlatline).evaluate(ctx, w)
+		}),
+	})
+}
+
+func (r *RuleFlatline) validate() error {
+	if r.Threshold <= 0 {
+		return fmt.Errorf("threshold must be greater than 0")
+	}
+	return validDuration("timeframe", r.TimeFrame, true)
+}
+
+// lowKeys records the query_key values counted within the current timeframe, and returns the known values
+// whose count is below threshold, ordered by their string form. A known value missing from counts had no events.
+// With forget_keys the returned values are no longer tracked.
+func (r *RuleFlatline) lowKeys(counts map[string]int64, values map[string]interface{}) []interface{} {
+	if r.knownKeys == nil {
+		r.knownKeys = make(map[string]interface{})
+	}
+	for k, v := range values {
+		r.knownKeys[k] = v
+	}
+
+	var keys []string
+	for k := range r.knownKeys {
+		if counts[k] < int64(r.Threshold) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	low := make([]interface{}, 0, len(keys))
+	for _, k := range keys {
+		low = append(low, r.knownKeys[k])
+		if r.ForgetKeys {
+			delete(r.knownKeys, k)
+		}
+	}
+	return low
+}
+
+func (r *RuleFlatline) evaluate(ctx context.Context, w *QueryWindow) ([]Match, error) {
+	timeframe, err := r.TimeFrame.Duration()
+	if err != nil {
+		return nil, fmt.Errorf("parse timeframe err: %s", err.Error())
+	}
+
+	query := BuildQuery(r, w.End.Add(-timeframe), w.End)
+	newMatch := func(key interface{}, count int64) Match {
+		return Match{
+			Timestamp: w.End,
+			QueryKey:  key,
+			Data: map[string]interface{}{
+				"count":     count,
+				"threshold": r.Threshold,
+			},
+		}
+	}
+
+	if r.QueryKey == "" {
+		count, err := w.Client.Count(r.GetIndex()).Query(query).Do(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("count index: %s err: %s", r.GetIndex(), err.Error())
+		}
+		w.Hits += count
+		if count < int64(r.Threshold) {
+			return []Match{newMatch(nil, count)}, nil
+		}
+		return nil, nil
+	}
+
+	res, err := w.SearchAggregations(ctx, r, query, keyAggregations(r.QueryKey, w.Config.MaxQuerySize, nil))
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int64)
+	values := make(map[string]interface{})
+	if terms, ok := res.Terms("by_key"); ok {
+		for _, bucket := range terms.Buckets {
+			k := fmt.Sprint(bucket.Key)
+			counts[k] = bucket.DocCount
+			values[k] = bucket.Key
+		}
+	}
+
+	var matches []Match
+	for _, v := range r.lowKeys(counts, values) {
+		matches = append(matches, newMatch(v, counts[fmt.Sprint(v)]))
+	}
+	return matches, nil
+}
@@ -0,0 +1,37 @@
+/**
+ * Created by GoLand.
+ * @author: clyde
+ * @date: 2021/11/01 下午2:26
+ * @note:
+ */
+
+package elastalert
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRuleFlatlineLowKeys(t *testing.T) {
+	rl := &RuleFlatline{Threshold: 2}
+
+	low := rl.lowKeys(
+		map[string]int64{"web-1": 5, "web-2": 1},
+		map[string]interface{}{"web-1": "web-1", "web-2": "web-2"},
+	)
+	if want := []interface{}{"web-2"}; !reflect.DeepEqual(low, want) {
+		t.Errorf("got %v, want %v", low, want)
+	}
+
+	// web-1 stopped reporting, it is still known
+	low = rl.lowKeys(map[string]int64{"web-2": 3}, map[string]interface{}{"web-2": "web-2"})
+	if want := []interface{}{"web-1"}; !reflect.DeepEqual(low, want) {
+		t.Errorf("got %v, want %v", low, want)
+	}
+
+	rl.ForgetKeys = true
+	rl.lowKeys(map[string]int64{"web-2": 3}, nil)
+	if low = rl.lowKeys(map[string]int64{"web-2": 3}, nil); len(low) != 0 {
+		t.Errorf("expected web-1 to be forgotten, got %v", low)
+	}
+}
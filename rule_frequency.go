@@ -0,0 +1,81 @@
+/**
+ * Created by GoLand.
+ * @author: clyde
+ * @date: 2021/10/22 上午11:03
+ * @note: frequency rule matches when at least num_events events occur within timeframe
+ */
+
+package elastalert
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+func init() {
+	RegisterRuleType("frequency", RuleType{
+		New:      func() Rule { return &RuleFrequency{} },
+		Validate: func(rl Rule) error { return rl.(*RuleFrequency).validate() },
+		Engine: RuleEngineFunc(func(ctx context.Context, rl Rule, w *QueryWindow) ([]Match, error) {
+			return rl.(*RuleFrequency).evaluate(ctx, w)
+		}),
+	})
+}
+
+func (r *RuleFrequency) validate() error {
+	if r.NumEvents <= 0 {
+		return fmt.Errorf("num_events must be greater than 0")
+	}
+	return validDuration("timeframe", r.TimeFrame, true)
+}
+
+// addEvent records an event of key at ts, it returns the number of events within timeframe
+// and whether num_events has been reached, in which case the window of key is cleared.
+func (r *RuleFrequency) addEvent(key string, ts time.Time, timeframe time.Duration) (int, bool) {
+	if r.occurrences == nil {
+		r.occurrences = make(map[string][]time.Time)
+	}
+
+	events := append(r.occurrences[key], ts)
+	for len(events) > 0 && events[0].Before(ts.Add(-timeframe)) {
+		events = events[1:]
+	}
+
+	if len(events) >= r.NumEvents {
+		delete(r.occurrences, key)
+		return len(events), true
+	}
+	r.occurrences[key] = events
+	return len(events), false
+}
+
+// expire drops the windows whose latest event is older than timeframe before now.
+func (r *RuleFrequency) expire(now time.Time, timeframe time.Duration) {
+	for key, events := range r.occurrences {
+		if len(events) == 0 || events[len(events)-1].Before(now.Add(-timeframe)) {
+			delete(r.occurrences, key)
+		}
+	}
+}
+
+func (r *RuleFrequency) evaluate(ctx context.Context, w *QueryWindow) ([]Match, error) {
+	timeframe, err := r.TimeFrame.Duration()
+	if err != nil {
+		return nil, fmt.Errorf("parse timeframe err: %s", err.Error())
+	}
+
+	docs, err := w.CollectDocs(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	var matches []Match
+	for _, d := range docs {
+		if n, matched := r.addEvent(queryKeyValue(d.Doc, r.QueryKey), d.Timestamp, timeframe); matched {
+			matches = append(matches, docMatch(d.Timestamp, d.Doc, r.QueryKey, map[string]interface{}{"num_hits": n}))
+		}
+	}
+
+	r.expire(w.End, timeframe)
+	return matches, nil
+}
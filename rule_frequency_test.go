@@ -0,0 +1,68 @@
+/**
+ * Created by GoLand.
+ * @author: clyde
+ * @date: 2021/10/22 下午2:17
+ * @note:
+ */
+
+package elastalert
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRuleFrequencyAddEvent(t *testing.T) {
+	rl := &RuleFrequency{RuleBase: RuleBase{NumEvents: 3}}
+	base := time.Date(2021, 10, 22, 0, 0, 0, 0, time.UTC)
+	timeframe := 10 * time.Minute
+
+	cases := []struct {
+		key     string
+		offset  time.Duration
+		count   int
+		matched bool
+	}{
+		{"a", 0, 1, false},
+		{"a", 5 * time.Minute, 2, false},
+		{"b", 6 * time.Minute, 1, false},
+		{"a", 12 * time.Minute, 2, false}, // the first event of a fell out of the window
+		{"a", 14 * time.Minute, 3, true},
+		{"a", 15 * time.Minute, 1, false}, // window is cleared after a match
+	}
+	for i, c := range cases {
+		count, matched := rl.addEvent(c.key, base.Add(c.offset), timeframe)
+		if count != c.count || matched != c.matched {
+			t.Errorf("case %d: got (%d, %v), want (%d, %v)", i, count, matched, c.count, c.matched)
+		}
+	}
+
+	rl.expire(base.Add(30*time.Minute), timeframe)
+	if len(rl.occurrences) != 0 {
+		t.Errorf("expected all windows to expire, got %v", rl.occurrences)
+	}
+}
+
+func TestRuleFrequencyScrollError(t *testing.T) {
+	now := time.Now()
+	s, w := newScrollEs(t, `{}`,
+		[]string{testDoc(now.Add(-30*time.Second), `"host": "web-1"`)},
+		[]string{testDoc(now.Add(-10*time.Second), `"host": "web-1"`)},
+	)
+	defer s.server.Close()
+	rl := &RuleFrequency{RuleBase: RuleBase{Name: "errors", Index: "logs-*", TimestampField: "@timestamp", NumEvents: 2, TimeFrame: "5m"}, QueryKey: "host"}
+
+	s.fail = true
+	if _, err := rl.evaluate(context.Background(), w); err == nil {
+		t.Fatal("expected the scroll to fail")
+	}
+	s.fail = false
+	matches, err := rl.evaluate(context.Background(), w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 1 || matches[0].Data["num_hits"] != 2 || len(rl.occurrences) != 0 {
+		t.Errorf("expected the retried window to match once, got %+v %v", matches, rl.occurrences)
+	}
+}
@@ -0,0 +1,115 @@
+/**
+ * Created by GoLand.
+ * @author: clyde
+ * @date: 2021/11/02 上午10:05
+ * @note: blacklist rule matches when compare_key is one of blacklist,
+ * whitelist rule matches when compare_key is not one of whitelist
+ */
+
+package elastalert
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+func init() {
+	RegisterRuleType("blacklist", RuleType{
+		New:      func() Rule { return &RuleBlacklist{} },
+		Validate: func(rl Rule) error { return rl.(*RuleBlacklist).validate() },
+		Engine: RuleEngineFunc(func(ctx context.Context, rl Rule, w *QueryWindow) ([]Match, error) {
+			return rl.(*RuleBlacklist).evaluate(ctx, w)
+		}),
+	})
+	RegisterRuleType("whitelist", RuleType{
+		New:      func() Rule { return &RuleWhitelist{} },
+		Validate: func(rl Rule) error { return rl.(*RuleWhitelist).validate() },
+		Engine: RuleEngineFunc(func(ctx context.Context, rl Rule, w *QueryWindow) ([]Match, error) {
+			return rl.(*RuleWhitelist).evaluate(ctx, w)
+		}),
+	})
+}
+
+func (r *RuleBlacklist) validate() error {
+	if r.CompareKey == "" {
+		return fmt.Errorf("compare_key is required")
+	}
+	return nil
+}
+
+func (r *RuleWhitelist) validate() error {
+	if r.CompareKey == "" {
+		return fmt.Errorf("compare_key is required")
+	}
+	return nil
+}
+
+// listedValues expands values into a set.
+func listedValues(values []string) (map[string]bool, error) {
+	expanded, err := ExpandValues(values)
+	if err != nil {
+		return nil, err
+	}
+	listed := make(map[string]bool, len(expanded))
+	for _, v := range expanded {
+		listed[v] = true
+	}
+	return listed, nil
+}
+
+// scrollCompareKey calls fn with every document in the window, its timestamp and the value of its compare_key.
+func scrollCompareKey(ctx context.Context, w *QueryWindow, rl Rule, compareKey string, fn func(doc map[string]interface{}, ts time.Time, value interface{}, ok bool)) error {
+	return w.ScrollDocs(ctx, rl, func(doc map[string]interface{}, ts time.Time) {
+		value, ok := LookupField(doc, compareKey)
+		fn(doc, ts, value, ok && value != nil)
+	})
+}
+
+func (r *RuleBlacklist) evaluate(ctx context.Context, w *QueryWindow) ([]Match, error) {
+	if r.listed == nil {
+		listed, err := listedValues(r.Blacklist)
+		if err != nil {
+			return nil, err
+		}
+		r.listed = listed
+	}
+
+	var matches []Match
+	err := scrollCompareKey(ctx, w, r, r.CompareKey, func(doc map[string]interface{}, ts time.Time, value interface{}, ok bool) {
+		if ok && r.listed[fmt.Sprint(value)] {
+			matches = append(matches, docMatch(ts, doc, "", nil))
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return matches, nil
+}
+
+func (r *RuleWhitelist) evaluate(ctx context.Context, w *QueryWindow) ([]Match, error) {
+	if r.listed == nil {
+		listed, err := listedValues(r.Whitelist)
+		if err != nil {
+			return nil, err
+		}
+		r.listed = listed
+	}
+
+	var matches []Match
+	err := scrollCompareKey(ctx, w, r, r.CompareKey, func(doc map[string]interface{}, ts time.Time, value interface{}, ok bool) {
+		if !ok {
+			if !r.IgnoreNull {
+				matches = append(matches, docMatch(ts, doc, "", nil))
+			}
+			return
+		}
+		if !r.listed[fmt.Sprint(value)] {
+			matches = append(matches, docMatch(ts, doc, "", nil))
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return matches, nil
+}
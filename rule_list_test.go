@@ -0,0 +1,91 @@
+/**
+ * Created by GoLand.
+ * @author: clyde
+ * @date: 2021/11/02 上午11:20
+ * @note:
+ */
+
+package elastalert
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// listDocs the documents the list rules are evaluated against, one page per scroll.
+func listDocs(now time.Time) [][]string {
+	return [][]string{
+		{
+			testDoc(now.Add(-50*time.Second), `"user": {"name": "root"}`),
+			testDoc(now.Add(-40*time.Second), `"user": {"name": "alice"}`),
+		},
+		{
+			testDoc(now.Add(-30*time.Second), `"user": {"name": null}`),
+			testDoc(now.Add(-20*time.Second), `"host": "web-1"`),
+			testDoc(now.Add(-10*time.Second), `"user": {"name": "admin"}`),
+		},
+	}
+}
+
+// listMatches returns the compare_key values of matches.
+func listMatches(matches []Match) string {
+	var got []string
+	for _, m := range matches {
+		v, _ := matchField(m, "user.name")
+		got = append(got, fmt.Sprint(v))
+	}
+	return fmt.Sprint(got)
+}
+
+func TestBlacklistEvaluate(t *testing.T) {
+	s, w := newScrollEs(t, "", listDocs(time.Now())...)
+	defer s.server.Close()
+
+	rl := &RuleBlacklist{
+		RuleBase:   RuleBase{Name: "users", Index: "logs-*", TimestampField: "@timestamp"},
+		CompareKey: "user.name",
+		Blacklist:  []string{"root", "admin"},
+	}
+	if err := rl.validate(); err != nil {
+		t.Fatal(err)
+	}
+	matches, err := rl.evaluate(context.Background(), w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := listMatches(matches); got != "[root admin]" {
+		t.Errorf("expected root and admin to match, got %s", got)
+	}
+}
+
+func TestWhitelistEvaluate(t *testing.T) {
+	s, w := newScrollEs(t, "", listDocs(time.Now())...)
+	defer s.server.Close()
+
+	rl := &RuleWhitelist{
+		RuleBase:   RuleBase{Name: "users", Index: "logs-*", TimestampField: "@timestamp"},
+		CompareKey: "user.name",
+		Whitelist:  []string{"alice", "bob"},
+	}
+	if err := rl.validate(); err != nil {
+		t.Fatal(err)
+	}
+	matches, err := rl.evaluate(context.Background(), w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a null or missing compare_key is not whitelisted
+	if got := listMatches(matches); got != "[root <nil> <nil> admin]" {
+		t.Errorf("expected root, the documents without user.name and admin to match, got %s", got)
+	}
+
+	rl.IgnoreNull = true
+	if matches, err = rl.evaluate(context.Background(), w); err != nil {
+		t.Fatal(err)
+	}
+	if got := listMatches(matches); got != "[root admin]" {
+		t.Errorf("expected ignore_null to skip the documents without user.name, got %s", got)
+	}
+}
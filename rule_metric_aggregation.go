@@ -0,0 +1,82 @@
+/**
+ * Created by GoLand.
+ * @author: clyde
+ * @date: 2021/10/28 上午10:20
+ * @note: metric_aggregation rule matches when a metric of metric_agg_key is outside min_threshold/max_threshold
+ */
+
+package elastalert
+
+import (
+	"context"
+	"fmt"
+	"github.com/olivere/elastic/v7"
+	"time"
+)
+
+func init() {
+	RegisterRuleType("metric_aggregation", RuleType{
+		New:      func() Rule { return &RuleMetricAggregation{} },
+		Validate: func(rl Rule) error { return rl.(*RuleMetricAggregation).validate() },
+		Engine: RuleEngineFunc(func(ctx context.Context, rl Rule, w *QueryWindow) ([]Match, error) {
+			return rl.(*RuleMetricAggregation).evaluate(ctx, w)
+		}),
+	})
+}
+
+func (r *RuleMetricAggregation) validate() error {
+	if r.MinThreshold == nil && r.MaxThreshold == nil {
+		return fmt.Errorf("min_threshold or max_threshold is required")
+	}
+	if _, err := metricAggregation(r.MetricAggType, r.MetricAggKey, r.PercentileRange); err != nil {
+		return err
+	}
+	return r.AggregationBase.validate()
+}
+
+// outOfRange reports whether value violates min_threshold or max_threshold.
+func (r *RuleMetricAggregation) outOfRange(value float64) bool {
+	if r.MinThreshold != nil && value < *r.MinThreshold {
+		return true
+	}
+	return r.MaxThreshold != nil && value > *r.MaxThreshold
+}
+
+func (r *RuleMetricAggregation) evaluate(ctx context.Context, w *QueryWindow) ([]Match, error) {
+	metric, err := metricAggregation(r.MetricAggType, r.MetricAggKey, r.PercentileRange)
+	if err != nil {
+		return nil, err
+	}
+
+	qStart, qEnd, err := r.queryWindow(w.Config, w.Start, w.End)
+	if err != nil {
+		return nil, err
+	}
+
+	aggs, err := r.bucketAggregations(r.GetTimestampField(), w.Config.MaxQuerySize, map[string]elastic.Aggregation{
+		"metric": metric,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := w.SearchAggregations(ctx, r, BuildQuery(r, qStart, qEnd), aggs)
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []Match
+	name := Concat("metric_", r.MetricAggKey, "_", r.MetricAggType)
+	r.walkBuckets(res, qEnd, func(leaf elastic.Aggregations, ts time.Time, key interface{}) {
+		value, ok := metricValue(leaf, "metric", r.MetricAggType)
+		if !ok || !r.outOfRange(value) {
+			return
+		}
+		matches = append(matches, Match{
+			Timestamp: ts,
+			QueryKey:  key,
+			Data:      map[string]interface{}{name: value},
+		})
+	})
+	return matches, nil
+}
@@ -0,0 +1,97 @@
+/**
+ * Created by GoLand.
+ * @author: clyde
+ * @date: 2021/10/27 下午5:20
+ * @note:
+ */
+
+package elastalert
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestMetricAggregationEvaluate(t *testing.T) {
+	t1 := time.Date(2021, 10, 27, 10, 0, 0, 0, time.UTC)
+	t2 := t1.Add(time.Minute)
+	bucket := func(ts time.Time, docs int, value string) string {
+		return fmt.Sprintf(`{"key": %d, "doc_count": %d, "metric": {"value": %s}}`, toMillis(ts), docs, value)
+	}
+	pct := func(ts time.Time, docs int, value string) string {
+		return fmt.Sprintf(`{"key": %d, "doc_count": %d, "metric": {"values": {"95.0": %s}}}`, toMillis(ts), docs, value)
+	}
+	threshold := func(v float64) *float64 { return &v }
+
+	cases := []struct {
+		name     string
+		rl       *RuleMetricAggregation
+		aggs     string
+		expected []string // query_key@time=value of the matches
+	}{
+		{
+			name: "max_threshold per query_key",
+			rl: &RuleMetricAggregation{
+				AggregationBase: AggregationBase{QueryKey: "host", BucketInterval: "1m"},
+				MetricAggKey:    "cpu", MetricAggType: "avg", MaxThreshold: threshold(100),
+			},
+			aggs: `{"by_key": {"buckets": [
+				{"key": "web-1", "doc_count": 5, "by_time": {"buckets": [` + bucket(t1, 3, "50") + `,` + bucket(t2, 2, "150") + `]}},
+				{"key": "web-2", "doc_count": 1, "by_time": {"buckets": [` + bucket(t1, 1, "120") + `,` + bucket(t2, 0, "null") + `]}}
+			]}}`,
+			expected: []string{"web-1@10:01=150", "web-2@10:00=120"},
+		},
+		{
+			name: "min_threshold skips empty buckets",
+			rl: &RuleMetricAggregation{
+				AggregationBase: AggregationBase{BucketInterval: "1m"},
+				MetricAggKey:    "bytes", MetricAggType: "sum", MinThreshold: threshold(10),
+			},
+			aggs:     `{"by_time": {"buckets": [` + bucket(t1, 0, "0") + `,` + bucket(t2, 2, "5") + `]}}`,
+			expected: []string{"<nil>@10:01=5"},
+		},
+		{
+			name: "null percentiles are no value",
+			rl: &RuleMetricAggregation{
+				AggregationBase: AggregationBase{BucketInterval: "1m"},
+				MetricAggKey:    "latency", MetricAggType: "percentiles", PercentileRange: 95, MinThreshold: threshold(10),
+			},
+			aggs:     `{"by_time": {"buckets": [` + pct(t1, 0, "null") + `,` + pct(t2, 3, "null") + `,` + pct(t2.Add(time.Minute), 3, "4.5") + `]}}`,
+			expected: []string{"<nil>@10:02=4.5"},
+		},
+		{
+			name: "no bucket_interval",
+			rl: &RuleMetricAggregation{
+				MetricAggKey: "cpu", MetricAggType: "max", MinThreshold: threshold(10), MaxThreshold: threshold(90),
+			},
+			aggs:     `{"metric": {"value": 95}}`,
+			expected: []string{"<nil>@end=95"},
+		},
+	}
+	for _, c := range cases {
+		s, w := newScrollEs(t, c.aggs)
+		c.rl.RuleBase = RuleBase{Name: "metric", Index: "logs-*", TimestampField: "@timestamp"}
+		if err := c.rl.validate(); err != nil {
+			t.Fatalf("%s: %s", c.name, err.Error())
+		}
+		matches, err := c.rl.evaluate(context.Background(), w)
+		s.server.Close()
+		if err != nil {
+			t.Fatalf("%s: %s", c.name, err.Error())
+		}
+
+		var got []string
+		for _, m := range matches {
+			at := m.Timestamp.UTC().Format("15:04")
+			if m.Timestamp.Equal(w.End) {
+				at = "end"
+			}
+			got = append(got, fmt.Sprintf("%v@%s=%v", m.QueryKey, at, m.Data["metric_"+c.rl.MetricAggKey+"_"+c.rl.MetricAggType]))
+		}
+		if fmt.Sprint(got) != fmt.Sprint(c.expected) {
+			t.Errorf("%s: expected matches %v, got %v", c.name, c.expected, got)
+		}
+	}
+}
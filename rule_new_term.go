@@ -0,0 +1,211 @@
+/**
+ * Created by GoLand.
+ * @author: clyde
+ * @date: 2021/10/26 上午10:36
+ * @note: new_term rule matches when a field holds a value not seen within terms_window_size
+ */
+
+package elastalert
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/olivere/elastic/v7"
+	"log"
+	"strings"
+	"time"
+)
+
+func init() {
+	RegisterRuleType("new_term", RuleType{
+		New:      func() Rule { return &RuleNewTerm{UseKeywordPostfix: true} },
+		Validate: func(rl Rule) error { return rl.(*RuleNewTerm).validate() },
+		Engine: RuleEngineFunc(func(ctx context.Context, rl Rule, w *QueryWindow) ([]Match, error) {
+			return rl.(*RuleNewTerm).evaluate(ctx, w)
+		}),
+	})
+}
+
+func (r *RuleNewTerm) validate() error {
+	if _, err := r.termFields(); err != nil {
+		return err
+	}
+	return validDuration("terms_window_size", r.TermsWindowSize, false)
+}
+
+// termFields returns the monitored fields, each entry holds a single field or the fields of a composite term.
+func (r *RuleNewTerm) termFields() ([][]string, error) {
+	if len(r.Fields) == 0 {
+		return nil, fmt.Errorf("fields is required")
+	}
+
+	groups := make([][]string, 0, len(r.Fields))
+	for _, f := range r.Fields {
+		switch v := f.(type) {
+		case string:
+			groups = append(groups, []string{v})
+		case []interface{}:
+			fields := make([]string, 0, len(v))
+			for _, sub := range v {
+				s, ok := sub.(string)
+				if !ok {
+					return nil, fmt.Errorf("invalid composite field: %v", v)
+				}
+				fields = append(fields, s)
+			}
+			if len(fields) == 0 {
+				return nil, fmt.Errorf("empty composite field")
+			}
+			groups = append(groups, fields)
+		default:
+			return nil, fmt.Errorf("invalid field: %v", f)
+		}
+	}
+	return groups, nil
+}
+
+// addTerm records values as a term of fields, it returns true when the term had not been seen before.
+func (r *RuleNewTerm) addTerm(fields []string, values []interface{}) bool {
+	if r.knownTerms == nil {
+		r.knownTerms = make(map[string]map[string]bool)
+	}
+	name := strings.Join(fields, ",")
+	if r.knownTerms[name] == nil {
+		r.knownTerms[name] = make(map[string]bool)
+	}
+
+	b, _ := json.Marshal(values)
+	key := string(b)
+	if r.knownTerms[name][key] {
+		return false
+	}
+	r.knownTerms[name][key] = true
+	return true
+}
+
+// aggField returns the field the terms of f are aggregated on, the keyword subfield of a text field.
+func (r *RuleNewTerm) aggField(cfg *Config, f string) string {
+	suffix := cfg.StringMultiFieldName
+	if !r.UseKeywordPostfix || suffix == "" || strings.HasSuffix(f, suffix) {
+		return f
+	}
+	return f + suffix
+}
+
+// docField returns the field f in the documents, a keyword subfield is only indexed.
+func (r *RuleNewTerm) docField(cfg *Config, f string) string {
+	if cfg.StringMultiFieldName == "" {
+		return f
+	}
+	return strings.TrimSuffix(f, cfg.StringMultiFieldName)
+}
+
+// loadKnownTerms pages through a composite aggregation on fields over (start, end] and records every term found.
+func (r *RuleNewTerm) loadKnownTerms(ctx context.Context, w *QueryWindow, fields []string, start, end time.Time) error {
+	size := r.TermsSize
+	if size <= 0 {
+		size = 500
+	}
+
+	sources := make([]elastic.CompositeAggregationValuesSource, 0, len(fields))
+	for i, f := range fields {
+		sources = append(sources, elastic.NewCompositeAggregationTermsValuesSource(fmt.Sprintf("f%d", i)).Field(r.aggField(w.Config, f)))
+	}
+
+	var after map[string]interface{}
+	for {
+		agg := elastic.NewCompositeAggregation().Sources(sources...).Size(size)
+		if after != nil {
+			agg = agg.AggregateAfter(after)
+		}
+		res, err := w.SearchAggregations(ctx, r, BuildQuery(r, start, end), map[string]elastic.Aggregation{"terms": agg})
+		if err != nil {
+			return err
+		}
+
+		terms, ok := res.Composite("terms")
+		if !ok || len(terms.Buckets) == 0 {
+			return nil
+		}
+		for _, bucket := range terms.Buckets {
+			values := make([]interface{}, len(fields))
+			for i := range fields {
+				values[i] = bucket.Key[fmt.Sprintf("f%d", i)]
+			}
+			r.addTerm(fields, values)
+		}
+		if terms.AfterKey == nil {
+			return nil
+		}
+		after = terms.AfterKey
+	}
+}
+
+func (r *RuleNewTerm) evaluate(ctx context.Context, w *QueryWindow) ([]Match, error) {
+	groups, err := r.termFields()
+	if err != nil {
+		return nil, err
+	}
+
+	// load the terms seen in the window before the first query
+	if r.knownTerms == nil {
+		windowSize := r.TermsWindowSize
+		if windowSize == "" {
+			windowSize = "30d"
+		}
+		window, err := windowSize.Duration()
+		if err != nil {
+			return nil, fmt.Errorf("parse terms_window_size err: %s", err.Error())
+		}
+		r.knownTerms = make(map[string]map[string]bool)
+		for _, fields := range groups {
+			if err := r.loadKnownTerms(ctx, w, fields, w.Start.Add(-window), w.Start); err != nil {
+				r.knownTerms = nil
+				return nil, err
+			}
+		}
+		log.Printf("rule: %s loaded known terms of %d fields", r.GetName(), len(groups))
+	}
+
+	docs, err := w.CollectDocs(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	var matches []Match
+	for _, d := range docs {
+		doc, ts := d.Doc, d.Timestamp
+		for _, fields := range groups {
+			values := make([]interface{}, 0, len(fields))
+			missing := ""
+			for _, f := range fields {
+				v, ok := LookupField(doc, r.docField(w.Config, f))
+				if !ok || v == nil {
+					missing = f
+					break
+				}
+				values = append(values, v)
+			}
+
+			if missing != "" {
+				if r.AlertOnMissingField {
+					matches = append(matches, docMatch(ts, doc, "", map[string]interface{}{"missing_field": missing}))
+				}
+				continue
+			}
+			if r.addTerm(fields, values) {
+				data := map[string]interface{}{"new_field": strings.Join(fields, ",")}
+				if len(values) == 1 {
+					data["new_value"] = values[0]
+				} else {
+					data["new_value"] = values
+				}
+				m := docMatch(ts, doc, "", data)
+				// realert silences each term on its own
+				m.SilenceKey = data["new_value"]
+				matches = append(matches, m)
+			}
+		}
+	}
+	return matches, nil
+}
@@ -0,0 +1,157 @@
+/**
+ * Created by GoLand.
+ * @author: clyde
+ * @date: 2021/10/26 下午3:05
+ * @note:
+ */
+
+package elastalert
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewTermSilencedPerTerm(t *testing.T) {
+	ts := time.Now().UTC().Format(time.RFC3339)
+	f, w := newScrollEs(t, `{"terms": {"buckets": [{"key": {"f0": "web-1"}, "doc_count": 3}]}}`, []string{
+		`{"@timestamp": "` + ts + `", "host": "web-1"}`,
+		`{"@timestamp": "` + ts + `", "host": "web-2"}`,
+		`{"@timestamp": "` + ts + `", "host": "web-3"}`,
+		`{"@timestamp": "` + ts + `", "host": "web-2"}`,
+	})
+	defer f.server.Close()
+
+	recorder := &recordAlerter{}
+	registerTestAlerter(t, "test_new_term", func(cfg *Config, rl Rule) (Alerter, error) { return recorder, nil })
+	rl := &RuleNewTerm{
+		RuleBase:          RuleBase{Name: "hosts", Index: "logs-*", TimestampField: "@timestamp", Alert: []string{"test_new_term"}},
+		Fields:            []interface{}{"host"},
+		UseKeywordPostfix: true,
+	}
+	matches, err := rl.evaluate(context.Background(), w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 2 || matches[0].SilenceKey != "web-2" || matches[1].SilenceKey != "web-3" || matches[0].QueryKey != nil {
+		t.Fatalf("expected new terms web-2 and web-3, got %+v", matches)
+	}
+
+	e := newTestAlerter(&Config{}, w.Client)
+	e.handleMatches(context.Background(), rl, matches)
+	if len(recorder.alerts) != 2 {
+		t.Errorf("expected an alert per new term within realert, got %d", len(recorder.alerts))
+	}
+}
+
+func TestNewTermFields(t *testing.T) {
+	rl := &RuleNewTerm{Fields: []interface{}{"host", []interface{}{"src_ip", "dst_ip"}}}
+	groups, err := rl.termFields()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 2 || strings.Join(groups[0], ",") != "host" || strings.Join(groups[1], ",") != "src_ip,dst_ip" {
+		t.Errorf("unexpected fields: %v", groups)
+	}
+
+	for _, fields := range [][]interface{}{nil, {1}, {[]interface{}{}}, {[]interface{}{"a", 2}}} {
+		rl.Fields = fields
+		if _, err := rl.termFields(); err == nil {
+			t.Errorf("expected fields: %v to be invalid", fields)
+		}
+	}
+}
+
+func TestNewTermAddTerm(t *testing.T) {
+	rl := &RuleNewTerm{}
+	cases := []struct {
+		fields []string
+		values []interface{}
+		added  bool
+	}{
+		{[]string{"host"}, []interface{}{"web-1"}, true},
+		{[]string{"host"}, []interface{}{"web-1"}, false},
+		{[]string{"host"}, []interface{}{"web-2"}, true},
+		{[]string{"port"}, []interface{}{"web-1"}, true}, // terms are per fields
+		{[]string{"port"}, []interface{}{float64(80)}, true},
+		{[]string{"port"}, []interface{}{"80"}, true}, // a string is not the number
+		{[]string{"src_ip", "dst_ip"}, []interface{}{"a", "b"}, true},
+		{[]string{"src_ip", "dst_ip"}, []interface{}{"b", "a"}, true},
+		{[]string{"src_ip", "dst_ip"}, []interface{}{"a", "b"}, false},
+	}
+	for i, c := range cases {
+		if added := rl.addTerm(c.fields, c.values); added != c.added {
+			t.Errorf("case %d: expected %v, got %v", i, c.added, added)
+		}
+	}
+}
+
+func TestNewTermKeywordField(t *testing.T) {
+	cfg := &Config{StringMultiFieldName: ".keyword"}
+	rl := &RuleNewTerm{UseKeywordPostfix: true}
+	for f, expected := range map[string][2]string{
+		"host":         {"host.keyword", "host"},
+		"host.keyword": {"host.keyword", "host"},
+	} {
+		if agg, doc := rl.aggField(cfg, f), rl.docField(cfg, f); agg != expected[0] || doc != expected[1] {
+			t.Errorf("field: %s expected %v, got %s %s", f, expected, agg, doc)
+		}
+	}
+	rl.UseKeywordPostfix = false
+	if agg := rl.aggField(cfg, "status"); agg != "status" {
+		t.Errorf("expected no postfix, got %s", agg)
+	}
+
+	// the known terms are loaded from host.keyword and matched against host in the documents
+	ts := time.Now().UTC().Format(time.RFC3339)
+	f, w := newScrollEs(t, `{"terms": {"buckets": [{"key": {"f0": "web-1"}, "doc_count": 3}]}}`, []string{
+		`{"@timestamp": "` + ts + `", "host": "web-1"}`,
+		`{"@timestamp": "` + ts + `", "host": "web-2"}`,
+	})
+	defer f.server.Close()
+	rl = &RuleNewTerm{
+		RuleBase:            RuleBase{Name: "hosts", Index: "logs-*", TimestampField: "@timestamp"},
+		Fields:              []interface{}{"host.keyword"},
+		UseKeywordPostfix:   true,
+		AlertOnMissingField: true,
+	}
+	matches, err := rl.evaluate(context.Background(), w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 1 || matches[0].Data["new_value"] != "web-2" {
+		t.Errorf("expected new term web-2, got %+v", matches)
+	}
+	if aggs := f.find(http.MethodPost, "/logs-*/_search"); len(aggs) == 0 || !strings.Contains(string(aggs[0]), `"field":"host.keyword"`) {
+		t.Errorf("expected the terms of host.keyword to be loaded: %q", aggs)
+	}
+}
+
+func TestNewTermScrollError(t *testing.T) {
+	now := time.Now()
+	s, w := newScrollEs(t, `{"terms": {"buckets": []}}`,
+		[]string{testDoc(now, `"host": "web-1"`)},
+		[]string{testDoc(now, `"host": "web-2"`)},
+	)
+	defer s.server.Close()
+	rl := &RuleNewTerm{
+		RuleBase: RuleBase{Name: "hosts", Index: "logs-*", TimestampField: "@timestamp"},
+		Fields:   []interface{}{"host"},
+	}
+
+	s.fail = true
+	if _, err := rl.evaluate(context.Background(), w); err == nil {
+		t.Fatal("expected the scroll to fail")
+	}
+	s.fail = false
+	matches, err := rl.evaluate(context.Background(), w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 2 {
+		t.Errorf("expected the terms of the failed window to match on retry, got %+v", matches)
+	}
+}
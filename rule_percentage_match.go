@@ -0,0 +1,100 @@
+/**
+ * Created by GoLand.
+ * @author: clyde
+ * @date: 2021/10/27 下午2:12
+ * @note: percentage_match rule matches when the share of documents matching match_bucket_filter is out of range
+ */
+
+package elastalert
+
+import (
+	"context"
+	"fmt"
+	"github.com/olivere/elastic/v7"
+	"time"
+)
+
+func init() {
+	RegisterRuleType("percentage_match", RuleType{
+		New:      func() Rule { return &RulePercentageMatch{} },
+		Validate: func(rl Rule) error { return rl.(*RulePercentageMatch).validate() },
+		Engine: RuleEngineFunc(func(ctx context.Context, rl Rule, w *QueryWindow) ([]Match, error) {
+			return rl.(*RulePercentageMatch).evaluate(ctx, w)
+		}),
+	})
+}
+
+func (r *RulePercentageMatch) validate() error {
+	if r.MatchBucketFilter == nil {
+		return fmt.Errorf("match_bucket_filter is required")
+	}
+	if r.MinPercentage <= 0 && r.MaxPercentage <= 0 {
+		return fmt.Errorf("min_percentage or max_percentage is required")
+	}
+	return r.AggregationBase.validate()
+}
+
+// outOfRange reports whether percentage violates min_percentage or max_percentage.
+func (r *RulePercentageMatch) outOfRange(percentage float64) bool {
+	if r.MinPercentage > 0 && percentage < r.MinPercentage {
+		return true
+	}
+	return r.MaxPercentage > 0 && percentage > r.MaxPercentage
+}
+
+func (r *RulePercentageMatch) evaluate(ctx context.Context, w *QueryWindow) ([]Match, error) {
+	qStart, qEnd, err := r.queryWindow(w.Config, w.Start, w.End)
+	if err != nil {
+		return nil, err
+	}
+
+	matchBucket := elastic.NewFiltersAggregation().
+		FilterWithName("match_bucket", elastic.NewBoolQuery().Filter(filterClauses(r.MatchBucketFilter)...)).
+		OtherBucket(true).
+		OtherBucketKey("_other_")
+	aggs, err := r.bucketAggregations(r.GetTimestampField(), w.Config.MaxQuerySize, map[string]elastic.Aggregation{
+		"percentage_match": matchBucket,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := w.SearchAggregations(ctx, r, BuildQuery(r, qStart, qEnd), aggs)
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []Match
+	r.walkBuckets(res, qEnd, func(leaf elastic.Aggregations, ts time.Time, key interface{}) {
+		filters, ok := leaf.Filters("percentage_match")
+		if !ok {
+			return
+		}
+		var matched, other int64
+		if b, ok := filters.NamedBuckets["match_bucket"]; ok {
+			matched = b.DocCount
+		}
+		if b, ok := filters.NamedBuckets["_other_"]; ok {
+			other = b.DocCount
+		}
+
+		total := matched + other
+		if total == 0 || total < int64(r.MinDenominator) {
+			return
+		}
+		percentage := float64(matched) * 100 / float64(total)
+		if !r.outOfRange(percentage) {
+			return
+		}
+
+		matches = append(matches, Match{
+			Timestamp: ts,
+			QueryKey:  key,
+			Data: map[string]interface{}{
+				"percentage":  percentage,
+				"denominator": total,
+			},
+		})
+	})
+	return matches, nil
+}
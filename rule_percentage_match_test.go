@@ -0,0 +1,56 @@
+/**
+ * Created by GoLand.
+ * @author: clyde
+ * @date: 2021/10/26 下午5:30
+ * @note:
+ */
+
+package elastalert
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestPercentageMatchEvaluate(t *testing.T) {
+	bucket := func(key string, matched, other int) string {
+		return fmt.Sprintf(`{"key": "%s", "doc_count": %d, "percentage_match": {"buckets": {"match_bucket": {"doc_count": %d}, "_other_": {"doc_count": %d}}}}`,
+			key, matched+other, matched, other)
+	}
+	s, w := newScrollEs(t, `{"by_key": {"buckets": [`+strings.Join([]string{
+		bucket("web-1", 1, 999), // 0.1%
+		bucket("web-2", 50, 50), // 50%
+		bucket("web-3", 9, 1),   // 90%, below min_denominator
+		bucket("web-4", 0, 0),
+		bucket("web-5", 95, 5), // 95%
+	}, ",")+`]}}`)
+	defer s.server.Close()
+
+	rl := &RulePercentageMatch{
+		RuleBase:          RuleBase{Name: "errors", Index: "logs-*", TimestampField: "@timestamp"},
+		AggregationBase:   AggregationBase{QueryKey: "host"},
+		MatchBucketFilter: map[string]interface{}{"term": map[string]interface{}{"status": 500}},
+		MinPercentage:     0.5,
+		MaxPercentage:     90,
+		MinDenominator:    20,
+	}
+	if err := rl.validate(); err != nil {
+		t.Fatal(err)
+	}
+	matches, err := rl.evaluate(context.Background(), w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, m := range matches {
+		got = append(got, fmt.Sprintf("%v=%v/%v", m.QueryKey, m.Data["percentage"], m.Data["denominator"]))
+	}
+	if expected := "[web-1=0.1/1000 web-5=95/100]"; fmt.Sprint(got) != expected {
+		t.Errorf("expected matches %s, got %v", expected, got)
+	}
+	if body := s.find("POST", "/logs-*/_search"); len(body) != 1 || !strings.Contains(string(body[0]), `"match_bucket":{"bool":{"filter":{"term":{"status":500}}}}`) {
+		t.Errorf("unexpected search: %q", body)
+	}
+}
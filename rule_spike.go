@@ -0,0 +1,120 @@
+/**
+ * Created by GoLand.
+ * @author: clyde
+ * @date: 2021/10/28 下午3:02
+ * @note: spike rule matches when the number of events in the current timeframe differs from the previous
+ * (reference) timeframe by spike_height
+ */
+
+package elastalert
+
+import (
+	"context"
+	"fmt"
+	"github.com/olivere/elastic/v7"
+	"time"
+)
+
+func init() {
+	RegisterRuleType("spike", RuleType{
+		New:      func() Rule { return &RuleSpike{} },
+		Validate: func(rl Rule) error { return rl.(*RuleSpike).validate() },
+		Engine: RuleEngineFunc(func(ctx context.Context, rl Rule, w *QueryWindow) ([]Match, error) {
+			return rl.(*RuleSpike).evaluate(ctx, w)
+		}),
+	})
+}
+
+func (r *RuleSpike) validate() error {
+	if err := validSpike(r.SpikeHeight, r.SpikeType); err != nil {
+		return err
+	}
+	return validDuration("timeframe", r.TimeFrame, true)
+}
+
+// validSpike checks the spike settings shared by spike and spike_aggregation.
+func validSpike(height float64, spikeType string) error {
+	if height <= 0 {
+		return fmt.Errorf("spike_height must be greater than 0")
+	}
+	switch spikeType {
+	case "", "up", "down", "both":
+		return nil
+	default:
+		return fmt.Errorf("unsupported spike_type: %s", spikeType)
+	}
+}
+
+// isSpike reports whether cur is at least height times ref (up), or at most ref divided by height (down).
+// Events after an empty reference window are an up spike only with alertOnNewData.
+func isSpike(ref, cur, height float64, spikeType string, alertOnNewData bool) bool {
+	if ref == 0 {
+		return alertOnNewData && cur > 0 && spikeType != "down"
+	}
+	up := cur >= ref*height
+	down := cur <= ref/height
+	switch spikeType {
+	case "up":
+		return up
+	case "down":
+		return down
+	default:
+		return up || down
+	}
+}
+
+// spikeWindows returns a filters aggregation with a "ref" bucket for the reference window
+// (end-2*timeframe, end-timeframe] and a "cur" bucket for the current window (end-timeframe, end]
+func spikeWindows(tsField string, end time.Time, timeframe time.Duration) *elastic.FiltersAggregation {
+	refStart, curStart := end.Add(-2*timeframe), end.Add(-timeframe)
+	return elastic.NewFiltersAggregation().
+		FilterWithName("ref", elastic.NewRangeQuery(tsField).Gt(toMillis(refStart)).Lte(toMillis(curStart)).Format("epoch_millis")).
+		FilterWithName("cur", elastic.NewRangeQuery(tsField).Gt(toMillis(curStart)).Lte(toMillis(end)).Format("epoch_millis"))
+}
+
+// evaluate counts the events of the reference and current windows, per query_key when set.
+func (r *RuleSpike) evaluate(ctx context.Context, w *QueryWindow) ([]Match, error) {
+	timeframe, err := r.TimeFrame.Duration()
+	if err != nil {
+		return nil, fmt.Errorf("parse timeframe err: %s", err.Error())
+	}
+
+	aggs := keyAggregations(r.QueryKey, w.Config.MaxQuerySize, map[string]elastic.Aggregation{
+		"windows": spikeWindows(r.GetTimestampField(), w.End, timeframe),
+	})
+	res, err := w.SearchAggregations(ctx, r, BuildQuery(r, w.End.Add(-2*timeframe), w.End), aggs)
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []Match
+	walkKeys(res, r.QueryKey, func(leaf elastic.Aggregations, key interface{}) {
+		filters, ok := leaf.Filters("windows")
+		if !ok {
+			return
+		}
+		var ref, cur int64
+		if b, ok := filters.NamedBuckets["ref"]; ok {
+			ref = b.DocCount
+		}
+		if b, ok := filters.NamedBuckets["cur"]; ok {
+			cur = b.DocCount
+		}
+		if ref < int64(r.ThresholdRef) || cur < int64(r.ThresholdCur) {
+			return
+		}
+		if !isSpike(float64(ref), float64(cur), r.SpikeHeight, r.SpikeType, r.AlertOnNewData) {
+			return
+		}
+
+		matches = append(matches, Match{
+			Timestamp: w.End,
+			QueryKey:  key,
+			Data: map[string]interface{}{
+				"spike_count":     cur,
+				"reference_count": ref,
+			},
+		})
+	})
+	return matches, nil
+}
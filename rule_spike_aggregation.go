@@ -0,0 +1,98 @@
+/**
+ * Created by GoLand.
+ * @author: clyde
+ * @date: 2021/10/29 上午10:15
+ * @note: spike_aggregation rule matches when a metric of metric_agg_key in the current timeframe differs from
+ * the previous (reference) timeframe by spike_height
+ */
+
+package elastalert
+
+import (
+	"context"
+	"fmt"
+	"github.com/olivere/elastic/v7"
+)
+
+func init() {
+	RegisterRuleType("spike_aggregation", RuleType{
+		New:      func() Rule { return &RuleSpikeAggregation{} },
+		Validate: func(rl Rule) error { return rl.(*RuleSpikeAggregation).validate() },
+		Engine: RuleEngineFunc(func(ctx context.Context, rl Rule, w *QueryWindow) ([]Match, error) {
+			return rl.(*RuleSpikeAggregation).evaluate(ctx, w)
+		}),
+	})
+}
+
+func (r *RuleSpikeAggregation) validate() error {
+	if err := validSpike(r.SpikeHeight, r.SpikeType); err != nil {
+		return err
+	}
+	if _, err := metricAggregation(r.MetricAggType, r.MetricAggKey, r.PercentileRange); err != nil {
+		return err
+	}
+	return validDuration("timeframe", r.TimeFrame, true)
+}
+
+// evaluate computes the metric in the reference and current windows, per query_key when set.
+func (r *RuleSpikeAggregation) evaluate(ctx context.Context, w *QueryWindow) ([]Match, error) {
+	metric, err := metricAggregation(r.MetricAggType, r.MetricAggKey, r.PercentileRange)
+	if err != nil {
+		return nil, err
+	}
+	timeframe, err := r.TimeFrame.Duration()
+	if err != nil {
+		return nil, fmt.Errorf("parse timeframe err: %s", err.Error())
+	}
+
+	aggs := keyAggregations(r.QueryKey, w.Config.MaxQuerySize, map[string]elastic.Aggregation{
+		"windows": spikeWindows(r.GetTimestampField(), w.End, timeframe).SubAggregation("metric", metric),
+	})
+	res, err := w.SearchAggregations(ctx, r, BuildQuery(r, w.End.Add(-2*timeframe), w.End), aggs)
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []Match
+	name := Concat("metric_", r.MetricAggKey, "_", r.MetricAggType)
+	walkKeys(res, r.QueryKey, func(leaf elastic.Aggregations, key interface{}) {
+		filters, ok := leaf.Filters("windows")
+		if !ok {
+			return
+		}
+		refBucket, ok := filters.NamedBuckets["ref"]
+		if !ok {
+			return
+		}
+		curBucket, ok := filters.NamedBuckets["cur"]
+		if !ok {
+			return
+		}
+		// a window without documents has no metric value to compare
+		ref, ok := metricValue(refBucket.Aggregations, "metric", r.MetricAggType)
+		if !ok {
+			return
+		}
+		cur, ok := metricValue(curBucket.Aggregations, "metric", r.MetricAggType)
+		if !ok {
+			return
+		}
+		if ref < r.ThresholdRef || cur < r.ThresholdCur {
+			return
+		}
+		if !isSpike(ref, cur, r.SpikeHeight, r.SpikeType, r.AlertOnNewData) {
+			return
+		}
+
+		matches = append(matches, Match{
+			Timestamp: w.End,
+			QueryKey:  key,
+			Data: map[string]interface{}{
+				name:              cur,
+				"spike_count":     cur,
+				"reference_count": ref,
+			},
+		})
+	})
+	return matches, nil
+}
@@ -0,0 +1,67 @@
+/**
+ * Created by GoLand.
+ * @author: clyde
+ * @date: 2021/10/29 上午11:40
+ * @note:
+ */
+
+package elastalert
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestSpikeAggregationEvaluate(t *testing.T) {
+	bucket := func(key, ref, cur string) string {
+		window := func(value string) string {
+			docs := 5
+			if value == "null" {
+				docs = 0
+			}
+			return fmt.Sprintf(`{"doc_count": %d, "metric": {"value": %s}}`, docs, value)
+		}
+		return fmt.Sprintf(`{"key": "%s", "doc_count": 10, "windows": {"buckets": {"ref": %s, "cur": %s}}}`,
+			key, window(ref), window(cur))
+	}
+	s, w := newScrollEs(t, `{"by_key": {"buckets": [`+strings.Join([]string{
+		bucket("web-1", "10", "40"),   // up
+		bucket("web-2", "10", "20"),   // below spike_height
+		bucket("web-3", "2", "40"),    // ref below threshold_ref
+		bucket("web-4", "90", "4"),    // cur below threshold_cur
+		bucket("web-5", "null", "40"), // nothing to compare to
+		bucket("web-6", "90", "10"),   // down
+	}, ",")+`]}}`)
+	defer s.server.Close()
+
+	rl := &RuleSpikeAggregation{
+		RuleBase:       RuleBase{Name: "latency", Index: "logs-*", TimestampField: "@timestamp", TimeFrame: "1h"},
+		MetricAggKey:   "latency",
+		MetricAggType:  "avg",
+		QueryKey:       "host",
+		ThresholdRef:   5,
+		ThresholdCur:   5,
+		SpikeHeight:    3,
+		SpikeType:      "both",
+		AlertOnNewData: true,
+	}
+	if err := rl.validate(); err != nil {
+		t.Fatal(err)
+	}
+	matches, err := rl.evaluate(context.Background(), w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, m := range matches {
+		got = append(got, fmt.Sprintf("%v=%v/%v", m.QueryKey, m.Data["reference_count"], m.Data["metric_latency_avg"]))
+	}
+	if expected := "[web-1=10/40 web-6=90/10]"; fmt.Sprint(got) != expected {
+		t.Errorf("expected matches %s, got %v", expected, got)
+	}
+	if body := s.find("POST", "/logs-*/_search"); len(body) != 1 || !strings.Contains(string(body[0]), `"metric":{"avg":{"field":"latency"}}`) {
+		t.Errorf("unexpected search: %q", body)
+	}
+}
@@ -0,0 +1,35 @@
+/**
+ * Created by GoLand.
+ * @author: clyde
+ * @date: 2021/10/28 下午5:11
+ * @note:
+ */
+
+package elastalert
+
+import "testing"
+
+func TestIsSpike(t *testing.T) {
+	cases := []struct {
+		ref, cur  float64
+		spikeType string
+		newData   bool
+		want      bool
+	}{
+		{10, 30, "up", false, true},
+		{10, 30, "down", false, false},
+		{10, 29, "both", false, false},
+		{30, 10, "down", false, true},
+		{30, 10, "up", false, false},
+		{30, 10, "", false, true},  // defaults to both
+		{0, 5, "up", false, false}, // a new query_key value is no spike
+		{0, 5, "up", true, true},
+		{0, 5, "down", true, false},
+		{0, 0, "both", true, false},
+	}
+	for i, c := range cases {
+		if got := isSpike(c.ref, c.cur, 3, c.spikeType, c.newData); got != c.want {
+			t.Errorf("case %d: isSpike(%v, %v, 3, %q, %v) = %v, want %v", i, c.ref, c.cur, c.spikeType, c.newData, got, c.want)
+		}
+	}
+}
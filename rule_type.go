@@ -0,0 +1,80 @@
+/**
+ * Created by GoLand.
+ * @author: clyde
+ * @date: 2021/11/03 上午10:18
+ * @note: registry of the rule types, custom rule types can be added from other packages with RegisterRuleType
+ */
+
+package elastalert
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// RuleType describes how rules of one type are loaded and evaluated.
+type RuleType struct {
+	// New returns a pointer to a new config struct, which rule files of the type are unmarshaled into.
+	New func() Rule
+
+	// Validate checks a rule once it is loaded, rules failing it are skipped. It is optional.
+	Validate func(rl Rule) error
+
+	// Engine evaluates the rule on each tick.
+	Engine RuleEngine
+}
+
+var (
+	ruleTypesMu sync.RWMutex
+	ruleTypes   = make(map[string]RuleType)
+)
+
+// RegisterRuleType makes a rule type available under name, the value of the type setting in rule files.
+// It panics if name is registered twice or New or Engine is nil.
+func RegisterRuleType(name string, rt RuleType) {
+	ruleTypesMu.Lock()
+	defer ruleTypesMu.Unlock()
+
+	if rt.New == nil || rt.Engine == nil {
+		panic(fmt.Sprintf("elastalert: rule type %s lacks New or Engine", name))
+	}
+	if _, dup := ruleTypes[name]; dup {
+		panic(fmt.Sprintf("elastalert: rule type %s registered twice", name))
+	}
+	ruleTypes[name] = rt
+}
+
+// LookupRuleType returns the rule type registered under name.
+func LookupRuleType(name string) (RuleType, bool) {
+	ruleTypesMu.RLock()
+	defer ruleTypesMu.RUnlock()
+
+	rt, ok := ruleTypes[name]
+	return rt, ok
+}
+
+// RuleTypes returns the sorted names of the registered rule types.
+func RuleTypes() []string {
+	ruleTypesMu.RLock()
+	defer ruleTypesMu.RUnlock()
+
+	names := make([]string, 0, len(ruleTypes))
+	for name := range ruleTypes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// validDuration checks that the setting name holds a valid duration, which must be set when required.
+func validDuration(name string, d DurationStr, required bool) error {
+	v, err := d.Duration()
+	if err != nil {
+		return fmt.Errorf("parse %s err: %s", name, err.Error())
+	}
+	if required && v <= 0 {
+		return fmt.Errorf("%s is required", name)
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+/**
+ * Created by GoLand.
+ * @author: clyde
+ * @date: 2021/11/03 下午3:36
+ * @note:
+ */
+
+package elastalert
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type ruleCustom struct {
+	RuleBase `mapstructure:",squash"`
+	Level    int `mapstructure:"level"`
+}
+
+// registerTestRuleType registers rt under name until the end of the test.
+func registerTestRuleType(t *testing.T, name string, rt RuleType) {
+	RegisterRuleType(name, rt)
+	t.Cleanup(func() {
+		ruleTypesMu.Lock()
+		defer ruleTypesMu.Unlock()
+		delete(ruleTypes, name)
+	})
+}
+
+func TestRegisterRuleType(t *testing.T) {
+	registerTestRuleType(t, "test_custom", RuleType{
+		New: func() Rule { return &ruleCustom{} },
+		Validate: func(rl Rule) error {
+			if rl.(*ruleCustom).Level <= 0 {
+				return fmt.Errorf("level must be greater than 0")
+			}
+			return nil
+		},
+		Engine: RuleEngineFunc(func(ctx context.Context, rl Rule, w *QueryWindow) ([]Match, error) {
+			return []Match{{Timestamp: w.End, Data: map[string]interface{}{"level": rl.(*ruleCustom).Level}}}, nil
+		}),
+	})
+
+	dir, err := ioutil.TempDir("", "rules")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"valid.yaml":   "name: valid\ntype: test_custom\nindex: logs-*\nlevel: 3\n",
+		"invalid.yaml": "name: invalid\ntype: test_custom\nindex: logs-*\n",
+		"unknown.yaml": "name: unknown\ntype: no_such_type\nindex: logs-*\n",
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rules := NewFileRulesLoader(dir).Load()
+	if len(rules) != 1 {
+		t.Fatalf("expected 1 rule, got %d", len(rules))
+	}
+	rl, ok := rules[0].(*ruleCustom)
+	if !ok || rl.GetName() != "valid" || rl.Level != 3 {
+		t.Errorf("unexpected rule: %+v", rules[0])
+	}
+
+	rt, _ := LookupRuleType("test_custom")
+	end := time.Now()
+	matches, err := rt.Engine.Evaluate(context.Background(), rl, &QueryWindow{Start: end.Add(-time.Minute), End: end})
+	if err != nil || len(matches) != 1 || matches[0].Data["level"] != 3 {
+		t.Errorf("unexpected matches: %+v err: %v", matches, err)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected registering test_custom twice to panic")
+		}
+	}()
+	RegisterRuleType("test_custom", RuleType{New: func() Rule { return &ruleCustom{} }, Engine: rt.Engine})
+}
@@ -8,9 +8,14 @@
 package elastalert
 
 import (
+	"bytes"
+	"fmt"
 	"github.com/spf13/viper"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
 	"log"
-	"reflect"
+	"regexp"
+	"strings"
 )
 
 type RulesLoader interface {
@@ -20,37 +25,26 @@ type RulesLoader interface {
 type FileRulesLoaderOption func(*FileRulesLoader)
 
 type FileRulesLoader struct {
-	Path        string
-	Suffix      string
-	Descend     bool
-	ruleTypeMap map[string]Rule
-	rules       []Rule
-	loaded      bool
+	Path    string
+	Suffix  string
+	Descend bool
+	Config  *Config // the alerters of the rules are built with it to check their settings
+	rules   []Rule
+	loaded  bool
 }
 
 func NewFileRulesLoader(path string, options ...FileRulesLoaderOption) *FileRulesLoader {
 	l := &FileRulesLoader{
-		Path:        path,
-		Suffix:      "yaml",
-		Descend:     true,
-		ruleTypeMap: make(map[string]Rule),
+		Path:    path,
+		Suffix:  "yaml",
+		Descend: true,
+		Config:  &Config{},
 	}
 
 	for _, f := range options {
 		f(l)
 	}
 
-	l.ruleTypeMap = map[string]Rule{
-		"cardinality":        RuleCardinality{},
-		"change":             RuleChange{},
-		"frequency":          RuleFrequency{},
-		"new_term":           RuleNewTerm{},
-		"percentage_match":   RulePercentageMatch{},
-		"metric_aggregation": RuleMetricAggregation{},
-		"spike_aggregation":  RuleSpikeAggregation{},
-		"spike":              RuleSpike{},
-	}
-
 	return l
 }
 
@@ -61,6 +55,13 @@ func SetSuffix(s string) FileRulesLoaderOption {
 	}
 }
 
+// SetConfig set the config the alerters of the rules are checked with.
+func SetConfig(cfg *Config) FileRulesLoaderOption {
+	return func(l *FileRulesLoader) {
+		l.Config = cfg
+	}
+}
+
 // SetDescend recursively descend the rules directory
 func SetDescend(d bool) FileRulesLoaderOption {
 	return func(l *FileRulesLoader) {
@@ -95,30 +96,85 @@ func (l *FileRulesLoader) loadRule(in <-chan string) <-chan Rule {
 	out := make(chan Rule, cap(in))
 	go func() {
 		for path := range in {
+			b, err := ioutil.ReadFile(path)
+			if err != nil {
+				log.Printf("ReadFile err: %s from : %s", err.Error(), path)
+				continue
+			}
+			b = quoteFileTags(b)
 			runtimeViper := viper.New()
 			runtimeViper.SetConfigFile(path)
-			if err := runtimeViper.ReadInConfig(); err != nil {
-				log.Printf("ReadInConfig err: %s from : %s", err.Error(), path)
+			if err := runtimeViper.ReadConfig(bytes.NewReader(b)); err != nil {
+				log.Printf("ReadConfig err: %s from : %s", err.Error(), path)
 				continue
 			}
 			typ := runtimeViper.GetString("type")
-			ruleObj, ok := l.ruleTypeMap[typ]
+			rt, ok := LookupRuleType(typ)
 			if !ok {
-				log.Printf("unsupported type: %s", typ)
+				log.Printf("unsupported type: %s from file: %s", typ, path)
 				continue
 			}
-			val := reflect.New(reflect.TypeOf(ruleObj))
-			if err := runtimeViper.Unmarshal(val.Interface()); err != nil {
+			rule := rt.New()
+			if err := runtimeViper.Unmarshal(rule); err != nil {
 				log.Printf("Unmarshal err: %s from file: %s", err.Error(), path)
 				continue
 			}
-			out <- val.Elem().Interface().(Rule)
+			// viper lowercases the keys, the alerters get them as written, eg the labels of alertmanager
+			settings := make(map[string]interface{})
+			if err := yaml.Unmarshal(b, &settings); err != nil {
+				log.Printf("Unmarshal settings err: %s from file: %s", err.Error(), path)
+				continue
+			}
+			rule.SetSettings(normalize(settings).(map[string]interface{}))
+			if err := validateRule(l.Config, rt, rule); err != nil {
+				log.Printf("invalid rule: %s err: %s from file: %s", rule.GetName(), err.Error(), path)
+				continue
+			}
+			out <- rule
 		}
 		close(out)
 	}()
 	return out
 }
 
+// fileTagRe matches the list entries written "- !file /path", see ExpandValues.
+var fileTagRe = regexp.MustCompile(`(?m)^([ \t]*-[ \t]+)!file[ \t]+([^#\n]*?)[ \t\r]*(#.*)?$`)
+
+// quoteFileTags quotes the !file list entries of a rule file, yaml would take !file for a tag and drop it.
+func quoteFileTags(b []byte) []byte {
+	return fileTagRe.ReplaceAllFunc(b, func(line []byte) []byte {
+		m := fileTagRe.FindSubmatch(line)
+		return []byte(Concat(string(m[1]), "'!file ", strings.Replace(string(m[2]), "'", "''", -1), "'"))
+	})
+}
+
+// validateRule checks the settings common to every rule, then the ones of its type.
+func validateRule(cfg *Config, rt RuleType, rl Rule) error {
+	if rl.GetName() == "" {
+		return fmt.Errorf("name is required")
+	}
+	if rl.GetIndex() == "" {
+		return fmt.Errorf("index is required")
+	}
+	if err := validAlertText(rl); err != nil {
+		return err
+	}
+	if err := validRealert(rl); err != nil {
+		return err
+	}
+	if err := validAggregation(rl); err != nil {
+		return err
+	}
+	// a missing alerter setting fails now rather than on the first alert
+	if _, err := NewAlerters(cfg, rl); err != nil {
+		return err
+	}
+	if rt.Validate == nil {
+		return nil
+	}
+	return rt.Validate(rl)
+}
+
 func (l *FileRulesLoader) cut() {
 	for i := 0; i < len(l.rules); {
 		if l.rules[i] != nil {
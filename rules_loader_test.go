@@ -0,0 +1,125 @@
+/**
+ * Created by GoLand.
+ * @author: clyde
+ * @date: 2021/11/02 下午4:10
+ * @note:
+ */
+
+package elastalert
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// loadTestRule loads the rule file content through the FileRulesLoader.
+func loadTestRule(t *testing.T, content string) Rule {
+	dir, err := ioutil.TempDir("", "rules")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "rule.yaml"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	rules := NewFileRulesLoader(dir).Load()
+	if len(rules) != 1 {
+		t.Fatalf("expected 1 rule, got %d", len(rules))
+	}
+	return rules[0]
+}
+
+func TestLoadFileTag(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rules")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	list := filepath.Join(dir, "bl.txt")
+	if err := ioutil.WriteFile(list, []byte("10.0.0.1\n10.0.0.2\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	rule := `name: bad ips
+type: blacklist
+index: logs-*
+compare_key: source.ip
+blacklist:
+  - 127.0.0.1
+  - !file ` + list + `   # one ip per line
+  - "!file ` + list + `"
+`
+	if err := ioutil.WriteFile(filepath.Join(dir, "bl.yaml"), []byte(rule), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	rules := NewFileRulesLoader(dir).Load()
+	if len(rules) != 1 {
+		t.Fatalf("expected 1 rule, got %d", len(rules))
+	}
+	rl := rules[0].(*RuleBlacklist)
+	expected := []string{"127.0.0.1", "!file " + list, "!file " + list}
+	if !reflect.DeepEqual(rl.Blacklist, expected) {
+		t.Errorf("expected blacklist %q, got %q", expected, rl.Blacklist)
+	}
+	values, err := ExpandValues(rl.Blacklist)
+	if err != nil || len(values) != 5 || values[1] != "10.0.0.1" {
+		t.Errorf("unexpected values: %q %v", values, err)
+	}
+}
+
+func TestLoadSettingsCase(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rules")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	rule := `name: errors
+type: any
+index: logs-*
+http_post_payload:
+  hostName: host.name
+pagerduty_custom_details:
+  Service: service.name
+`
+	if err := ioutil.WriteFile(filepath.Join(dir, "errors.yaml"), []byte(rule), 0644); err != nil {
+		t.Fatal(err)
+	}
+	rules := NewFileRulesLoader(dir).Load()
+	if len(rules) != 1 {
+		t.Fatalf("expected 1 rule, got %d", len(rules))
+	}
+	settings := rules[0].GetSettings()
+	payload, _ := settings["http_post_payload"].(map[string]interface{})
+	details, _ := settings["pagerduty_custom_details"].(map[string]interface{})
+	if payload["hostName"] != "host.name" || details["Service"] != "service.name" {
+		t.Errorf("expected the keys as written, got %v %v", payload, details)
+	}
+}
+
+func TestLoadInvalidAlerter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rules")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"slack.yaml":   "name: slack\ntype: any\nindex: logs-*\nalert: slack\n",
+		"command.yaml": "name: command\ntype: any\nindex: logs-*\nalert: [debug, command]\n",
+		"debug.yaml":   "name: debug\ntype: any\nindex: logs-*\nalert: debug\n",
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rules := NewFileRulesLoader(dir).Load()
+	if len(rules) != 1 || rules[0].GetName() != "debug" {
+		t.Errorf("expected the rules lacking alerter settings to be rejected, got %d rules", len(rules))
+	}
+}
@@ -0,0 +1,44 @@
+/**
+ * Created by GoLand.
+ * @author: clyde
+ * @date: 2021/11/24 上午11:20
+ * @note:
+ */
+
+package elastalert
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScheduleNext(t *testing.T) {
+	from := time.Date(2021, 11, 24, 10, 30, 15, 0, time.UTC) // a wednesday
+	cases := []struct {
+		spec string
+		next time.Time
+	}{
+		{"* * * * *", time.Date(2021, 11, 24, 10, 31, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2021, 11, 24, 10, 45, 0, 0, time.UTC)},
+		{"0 9 * * mon-fri", time.Date(2021, 11, 25, 9, 0, 0, 0, time.UTC)},
+		{"0 9 * * sat,7", time.Date(2021, 11, 27, 9, 0, 0, 0, time.UTC)},
+		{"30 8 1 jan *", time.Date(2022, 1, 1, 8, 30, 0, 0, time.UTC)},
+		{"0 0 13 * fri", time.Date(2021, 11, 26, 0, 0, 0, 0, time.UTC)},
+		{"0 0 31 2 *", time.Time{}},
+	}
+	for _, c := range cases {
+		s, err := parseSchedule(c.spec)
+		if err != nil {
+			t.Fatalf("parse schedule: %s err: %s", c.spec, err.Error())
+		}
+		if next := s.next(from); !next.Equal(c.next) {
+			t.Errorf("schedule: %s expected next %s, got %s", c.spec, c.next, next)
+		}
+	}
+
+	for _, spec := range []string{"* * * *", "60 * * * *", "* * * foo *", "*/0 * * * *", "5-1 * * * *"} {
+		if _, err := parseSchedule(spec); err == nil {
+			t.Errorf("expected schedule: %s to be invalid", spec)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+/**
+ * Created by GoLand.
+ * @author: clyde
+ * @date: 2021/11/22 上午10:30
+ * @note: realert silences a rule and query_key value after an alert, the silences are written to
+ * <writeback_index>_silence so they survive restarts
+ */
+
+package elastalert
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/olivere/elastic/v7"
+	"log"
+	"time"
+)
+
+// Silence no alert of RuleName, the rule name followed by the query_key value, is sent until Until.
+type Silence struct {
+	RuleName  string    `json:"rule_name"`
+	Timestamp time.Time `json:"@timestamp"`
+	Until     time.Time `json:"until"`
+
+	// Exponent realert is doubled Exponent times with exponential_realert
+	Exponent int `json:"exponent"`
+}
+
+func validRealert(rl Rule) error {
+	realert, exponential, err := rl.GetRealert()
+	if err != nil {
+		return err
+	}
+	if exponential > 0 && exponential < realert {
+		return fmt.Errorf("exponential_realert must not be shorter than realert")
+	}
+	return nil
+}
+
+// silenceKey identifies the silence of a rule and query_key value.
+func silenceKey(rl Rule, queryKey interface{}) string {
+	if queryKey == nil {
+		return rl.GetName()
+	}
+	return fmt.Sprintf("%s.%v", rl.GetName(), queryKey)
+}
+
+// nextAlertTime returns when key may alert again after an alert at now, and the exponent of realert.
+// With exponential_realert the wait doubles for each alert within the previous wait, and halves for
+// each wait passed without alert, up to exponential_realert.
+func nextAlertTime(realert, exponential time.Duration, last *Silence, now time.Time) (time.Time, int) {
+	if last == nil || exponential <= 0 {
+		return now.Add(realert), 0
+	}
+
+	exponent := last.Exponent
+	diff := now.Sub(last.Until)
+	if diff < realert<<uint(exponent) {
+		exponent++
+	} else {
+		for exponent > 0 && diff > realert<<uint(exponent) {
+			diff -= realert << uint(exponent)
+			exponent--
+		}
+	}
+
+	wait := realert << uint(exponent)
+	if wait >= exponential {
+		return now.Add(exponential), exponent - 1
+	}
+	return now.Add(wait), exponent
+}
+
+// getSilence returns the silence of key, from the cache or else the writeback index.
+func (e *ElasticAlerter) getSilence(ctx context.Context, key string) (*Silence, error) {
+	if s, ok := e.silenceCache[key]; ok {
+		return s, nil
+	}
+	if e.cfg.WritebackIndex == "" {
+		return nil, nil
+	}
+
+	index := e.writebackIndex(writebackSilence)
+	res, err := e.esClient.Search(index).
+		Query(elastic.NewTermQuery("rule_name", key)).
+		Sort("until", false).
+		Size(1).
+		Do(ctx)
+	if elastic.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("search index: %s err: %s", index, err.Error())
+	}
+	if res.Hits == nil || len(res.Hits.Hits) == 0 {
+		return nil, nil
+	}
+
+	s := &Silence{}
+	if err := json.Unmarshal(res.Hits.Hits[0].Source, s); err != nil {
+		return nil, fmt.Errorf("decode silence err: %s", err.Error())
+	}
+	e.silenceCache[key] = s
+	return s, nil
+}
+
+// silenced reports whether key is silenced at now. When it isn't, key is silenced following realert
+// and the caller is expected to alert.
+func (e *ElasticAlerter) silenced(ctx context.Context, rl Rule, key string, now time.Time) bool {
+	realert, exponential, err := rl.GetRealert()
+	if err != nil || realert <= 0 {
+		return false
+	}
+
+	last, err := e.getSilence(ctx, key)
+	if err != nil {
+		log.Printf("rule: %s get silence: %s err: %s", rl.GetName(), key, err.Error())
+	}
+	if last != nil && now.Before(last.Until) {
+		return true
+	}
+
+	until, exponent := nextAlertTime(realert, exponential, last, now)
+	s := &Silence{RuleName: key, Timestamp: now, Until: until, Exponent: exponent}
+	e.silenceCache[key] = s
+	if _, err := e.writeback(ctx, writebackSilence, s); err != nil {
+		log.Printf("rule: %s write silence: %s err: %s", rl.GetName(), key, err.Error())
+	}
+	return false
+}
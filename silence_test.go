@@ -0,0 +1,89 @@
+/**
+ * Created by GoLand.
+ * @author: clyde
+ * @date: 2021/11/22 下午3:10
+ * @note:
+ */
+
+package elastalert
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNextAlertTime(t *testing.T) {
+	now := time.Now()
+	cases := []struct {
+		last     *Silence
+		exp      time.Duration
+		wait     time.Duration
+		exponent int
+	}{
+		{nil, time.Hour, time.Minute, 0},
+		{&Silence{Until: now.Add(-30 * time.Second)}, 0, time.Minute, 0},
+		{&Silence{Until: now.Add(-30 * time.Second)}, time.Hour, 2 * time.Minute, 1},
+		{&Silence{Until: now.Add(-10 * time.Minute), Exponent: 3}, time.Hour, 4 * time.Minute, 2},
+		{&Silence{Until: now, Exponent: 6}, time.Hour, time.Hour, 6},
+	}
+	for i, c := range cases {
+		until, exponent := nextAlertTime(time.Minute, c.exp, c.last, now)
+		if until.Sub(now) != c.wait || exponent != c.exponent {
+			t.Errorf("case %d: expected %s %d, got %s %d", i, c.wait, c.exponent, until.Sub(now), exponent)
+		}
+	}
+}
+
+func TestSilenced(t *testing.T) {
+	until := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	f, client := newFakeEs(t, func(method, path string, body []byte) (int, string) {
+		if !strings.HasSuffix(path, "/_search") {
+			return http.StatusCreated, `{"_id": "1", "result": "created"}`
+		}
+		var hits string
+		if strings.Contains(string(body), `"errors.web-1"`) {
+			hits = `{"_id": "1", "_source": {"rule_name": "errors.web-1", "until": "` + until.Format(time.RFC3339) + `"}}`
+		}
+		return http.StatusOK, `{"hits": {"total": {"value": 1}, "hits": [` + hits + `]}}`
+	})
+	defer f.server.Close()
+
+	e := newTestAlerter(&Config{WritebackIndex: "elastalert"}, client)
+	ctx := context.Background()
+	rl := &RuleBase{Name: "errors"}
+	now := time.Now()
+
+	// silenced before a restart
+	if !e.silenced(ctx, rl, silenceKey(rl, "web-1"), now) {
+		t.Errorf("expected errors.web-1 to be silenced from the writeback index")
+	}
+
+	key := silenceKey(rl, "web-2")
+	if e.silenced(ctx, rl, key, now) {
+		t.Errorf("expected %s not to be silenced", key)
+	}
+	if !e.silenced(ctx, rl, key, now.Add(30*time.Second)) {
+		t.Errorf("expected %s to be silenced within realert", key)
+	}
+	if e.silenced(ctx, rl, key, now.Add(2*time.Minute)) {
+		t.Errorf("expected %s not to be silenced after realert", key)
+	}
+
+	docs := f.find(http.MethodPost, "/elastalert_silence/_doc")
+	if len(docs) != 2 {
+		t.Fatalf("expected 2 silence docs, got %d", len(docs))
+	}
+	var s Silence
+	if err := json.Unmarshal(docs[0], &s); err != nil || s.RuleName != key || s.Until.Sub(now) != time.Minute {
+		t.Errorf("unexpected silence: %s", docs[0])
+	}
+
+	rl.Realert = "0m"
+	if e.silenced(ctx, rl, silenceKey(rl, "web-1"), now) {
+		t.Errorf("expected realert 0 to disable silences")
+	}
+}
@@ -9,6 +9,8 @@ package elastalert
 
 import (
 	"bytes"
+	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
@@ -27,7 +29,10 @@ func WalkDir(dir, suffix string, descend bool) <-chan string {
 	ext := Concat(".", strings.ToLower(suffix))
 	out := make(chan string)
 	go func() {
-		filepath.Walk(dir, func(path string, fi os.FileInfo, _ error) (err error) {
+		filepath.Walk(dir, func(path string, fi os.FileInfo, walkErr error) (err error) {
+			if walkErr != nil {
+				return
+			}
 			if fi.IsDir() && path != dir {
 				if descend {
 					return
@@ -71,6 +76,9 @@ func parallelWalkDir(dir, suffix string, descend bool, wg *sync.WaitGroup, out c
 
 	ext := Concat(".", strings.ToLower(suffix))
 	visit := func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
 		if fi.IsDir() && path != dir {
 			if descend {
 				wg.Add(1)
@@ -86,3 +94,45 @@ func parallelWalkDir(dir, suffix string, descend bool, wg *sync.WaitGroup, out c
 	}
 	filepath.Walk(dir, visit)
 }
+
+// LookupField returns the value of key in doc, key may be a dotted path into nested objects, eg "host.name"
+func LookupField(doc map[string]interface{}, key string) (interface{}, bool) {
+	if v, ok := doc[key]; ok {
+		return v, true
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] != '.' {
+			continue
+		}
+		sub, ok := doc[key[:i]].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if v, ok := LookupField(sub, key[i+1:]); ok {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// ExpandValues returns values with each "!file /path" entry replaced by the non empty lines of that file.
+func ExpandValues(values []string) ([]string, error) {
+	expanded := make([]string, 0, len(values))
+	for _, v := range values {
+		if !strings.HasPrefix(v, "!file ") {
+			expanded = append(expanded, v)
+			continue
+		}
+		path := strings.TrimSpace(strings.TrimPrefix(v, "!file "))
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("read file: %s err: %s", path, err.Error())
+		}
+		for _, line := range strings.Split(string(b), "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				expanded = append(expanded, line)
+			}
+		}
+	}
+	return expanded, nil
+}
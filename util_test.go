@@ -8,7 +8,10 @@
 package elastalert
 
 import (
+	"io/ioutil"
+	"os"
 	"os/exec"
+	"reflect"
 	"testing"
 	"time"
 )
@@ -45,3 +48,25 @@ func TestParallelWalkDir(t *testing.T) {
 		// use: 0.385026s found: 43293 files when descend
 	}
 }
+
+func TestExpandValues(t *testing.T) {
+	f, err := ioutil.TempFile("", "blacklist")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString("10.0.0.1\n\n 10.0.0.2 \n")
+	f.Close()
+
+	values, err := ExpandValues([]string{"127.0.0.1", "!file " + f.Name()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"127.0.0.1", "10.0.0.1", "10.0.0.2"}; !reflect.DeepEqual(values, want) {
+		t.Errorf("got %v, want %v", values, want)
+	}
+
+	if _, err := ExpandValues([]string{"!file /nonexistent"}); err == nil {
+		t.Errorf("expected an error for a missing file")
+	}
+}
@@ -0,0 +1,180 @@
+/**
+ * Created by GoLand.
+ * @author: clyde
+ * @date: 2021/11/18 上午10:15
+ * @note: writeback of the state of elastalert to es. Alerts go to <writeback_index>, status docs to
+ * <writeback_index>_status and silences to <writeback_index>_silence, nothing is written when writeback_index is empty
+ */
+
+package elastalert
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/olivere/elastic/v7"
+	"log"
+	"time"
+)
+
+const (
+	writebackAlert   = ""
+	writebackStatus  = "status"
+	writebackSilence = "silence"
+)
+
+// writebackMappings the properties of the writeback indices by doc type.
+var writebackMappings = map[string]string{
+	writebackAlert: `{
+		"rule_name":       {"type": "keyword"},
+		"@timestamp":      {"type": "date"},
+		"alert_time":      {"type": "date"},
+		"alert_sent":      {"type": "boolean"},
+		"abandoned":       {"type": "boolean"},
+		"failed":          {"type": "keyword"},
+		"retries":         {"type": "integer"},
+		"alert_exception": {"type": "text"},
+		"aggregation_key": {"type": "keyword"},
+		"match_body":      {"type": "object", "enabled": false}
+	}`,
+	writebackStatus: `{
+		"rule_name":  {"type": "keyword"},
+		"@timestamp": {"type": "date"},
+		"starttime":  {"type": "date"},
+		"endtime":    {"type": "date"},
+		"hits":       {"type": "long"},
+		"matches":    {"type": "long"},
+		"time_taken": {"type": "float"}
+	}`,
+	writebackSilence: `{
+		"rule_name":  {"type": "keyword"},
+		"@timestamp": {"type": "date"},
+		"until":      {"type": "date"},
+		"exponent":   {"type": "integer"}
+	}`,
+}
+
+// RuleStatus is written after each run of a rule.
+type RuleStatus struct {
+	RuleName  string    `json:"rule_name"`
+	Timestamp time.Time `json:"@timestamp"`
+	StartTime time.Time `json:"starttime"`
+	EndTime   time.Time `json:"endtime"`
+	Hits      int64     `json:"hits"`
+	Matches   int       `json:"matches"`
+
+	// TimeTaken seconds spent running the rule and sending its alerts
+	TimeTaken float64 `json:"time_taken"`
+}
+
+// writebackIndex returns the index of the doc type.
+func (e *ElasticAlerter) writebackIndex(docType string) string {
+	if docType == "" {
+		return e.cfg.WritebackIndex
+	}
+	return Concat(e.cfg.WritebackIndex, "_", docType)
+}
+
+// alertsIndex returns the index alerts are searched in, writeback_alias when set.
+func (e *ElasticAlerter) alertsIndex() string {
+	if e.cfg.WritebackAlias != "" {
+		return e.cfg.WritebackAlias
+	}
+	return e.writebackIndex(writebackAlert)
+}
+
+// initWriteback creates the missing writeback indices, the alerts index along with writeback_alias.
+func (e *ElasticAlerter) initWriteback(ctx context.Context) {
+	if e.cfg.WritebackIndex == "" {
+		return
+	}
+	for docType, properties := range writebackMappings {
+		index := e.writebackIndex(docType)
+		exists, err := e.esClient.IndexExists(index).Do(ctx)
+		if err != nil {
+			log.Printf("check writeback index: %s err: %s", index, err.Error())
+			continue
+		}
+		if exists {
+			continue
+		}
+		body := map[string]interface{}{
+			"mappings": map[string]interface{}{"properties": json.RawMessage(properties)},
+		}
+		if docType == writebackAlert && e.cfg.WritebackAlias != "" {
+			body["aliases"] = map[string]interface{}{e.cfg.WritebackAlias: map[string]interface{}{}}
+		}
+		if _, err := e.esClient.CreateIndex(index).BodyJson(body).Do(ctx); err != nil {
+			log.Printf("create writeback index: %s err: %s", index, err.Error())
+		}
+	}
+}
+
+// writeback indexes doc as docType, it returns the id of the new document.
+func (e *ElasticAlerter) writeback(ctx context.Context, docType string, doc interface{}) (string, error) {
+	if e.cfg.WritebackIndex == "" {
+		return "", nil
+	}
+	index := e.writebackIndex(docType)
+	res, err := e.esClient.Index().Index(index).BodyJson(doc).Do(ctx)
+	if err != nil {
+		return "", fmt.Errorf("writeback to index: %s err: %s", index, err.Error())
+	}
+	return res.Id, nil
+}
+
+// rewrite replaces the document id of index with doc, with refresh it returns once searches see doc.
+func (e *ElasticAlerter) rewrite(ctx context.Context, index, id string, doc interface{}, refresh bool) error {
+	svc := e.esClient.Index().Index(index).Id(id).BodyJson(doc)
+	if refresh {
+		svc = svc.Refresh("wait_for")
+	}
+	if _, err := svc.Do(ctx); err != nil {
+		return fmt.Errorf("rewrite index: %s doc: %s err: %s", index, id, err.Error())
+	}
+	return nil
+}
+
+// lastEndTime returns the end of the last run of rl recorded in the writeback index.
+func (e *ElasticAlerter) lastEndTime(ctx context.Context, rl Rule) (time.Time, bool, error) {
+	if e.cfg.WritebackIndex == "" {
+		return time.Time{}, false, nil
+	}
+	index := e.writebackIndex(writebackStatus)
+	res, err := e.esClient.Search(index).
+		Query(elastic.NewTermQuery("rule_name", rl.GetName())).
+		Sort("endtime", false).
+		Size(1).
+		Do(ctx)
+	if elastic.IsNotFound(err) {
+		return time.Time{}, false, nil
+	}
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("search index: %s err: %s", index, err.Error())
+	}
+	if res.Hits == nil || len(res.Hits.Hits) == 0 {
+		return time.Time{}, false, nil
+	}
+
+	var status RuleStatus
+	if err := json.Unmarshal(res.Hits.Hits[0].Source, &status); err != nil {
+		return time.Time{}, false, fmt.Errorf("decode status err: %s", err.Error())
+	}
+	return status.EndTime, !status.EndTime.IsZero(), nil
+}
+
+// writeStatus records a run of rl over w.
+func (e *ElasticAlerter) writeStatus(ctx context.Context, rl Rule, w *QueryWindow, matches int, took time.Duration) {
+	status := RuleStatus{
+		RuleName:  rl.GetName(),
+		Timestamp: time.Now(),
+		StartTime: w.Start,
+		EndTime:   w.End,
+		Hits:      w.Hits,
+		Matches:   matches,
+		TimeTaken: took.Seconds(),
+	}
+	if _, err := e.writeback(ctx, writebackStatus, status); err != nil {
+		log.Printf("rule: %s write status err: %s", rl.GetName(), err.Error())
+	}
+}
@@ -0,0 +1,161 @@
+/**
+ * Created by GoLand.
+ * @author: clyde
+ * @date: 2021/11/18 下午3:30
+ * @note:
+ */
+
+package elastalert
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/olivere/elastic/v7"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeEs records the requests it gets and answers them with respond, or an empty success.
+type fakeEs struct {
+	mu       sync.Mutex
+	requests []fakeEsRequest
+	respond  func(method, path string, body []byte) (int, string)
+	server   *httptest.Server
+}
+
+type fakeEsRequest struct {
+	method string
+	path   string
+	query  string
+	body   []byte
+}
+
+func newFakeEs(t *testing.T, respond func(method, path string, body []byte) (int, string)) (*fakeEs, *elastic.Client) {
+	f := &fakeEs{respond: respond}
+	f.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		path := strings.TrimSuffix(r.URL.Path, "/")
+		f.mu.Lock()
+		f.requests = append(f.requests, fakeEsRequest{method: r.Method, path: path, query: r.URL.RawQuery, body: body})
+		f.mu.Unlock()
+
+		code, resp := http.StatusOK, `{}`
+		if f.respond != nil {
+			code, resp = f.respond(r.Method, path, body)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		w.Write([]byte(resp))
+	}))
+	client, err := elastic.NewClient(elastic.SetURL(f.server.URL), elastic.SetSniff(false), elastic.SetHealthcheck(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f, client
+}
+
+// find returns the bodies of the requests with method to paths ending with suffix.
+func (f *fakeEs) find(method, suffix string) [][]byte {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var bodies [][]byte
+	for _, r := range f.requests {
+		if r.method == method && strings.HasSuffix(r.path, suffix) {
+			bodies = append(bodies, r.body)
+		}
+	}
+	return bodies
+}
+
+func newTestAlerter(cfg *Config, client *elastic.Client) *ElasticAlerter {
+	return &ElasticAlerter{
+		cfg:          cfg,
+		esClient:     client,
+		startTime:    time.Now(),
+		ruleEndTimes: make(map[string]time.Time),
+		ruleAlerters: make(map[string][]Alerter),
+		silenceCache: make(map[string]*Silence),
+		aggregates:   make(map[string]*pendingAggregate),
+	}
+}
+
+func TestWriteStatus(t *testing.T) {
+	registerTestRuleType(t, "test_status", RuleType{
+		New: func() Rule { return &RuleBase{} },
+		Engine: RuleEngineFunc(func(ctx context.Context, rl Rule, w *QueryWindow) ([]Match, error) {
+			w.Hits += 5
+			return []Match{{Timestamp: w.End}, {Timestamp: w.End}}, nil
+		}),
+	})
+
+	f, client := newFakeEs(t, func(method, path string, body []byte) (int, string) {
+		switch method {
+		case http.MethodHead:
+			return http.StatusNotFound, ``
+		case http.MethodPut:
+			return http.StatusOK, `{"acknowledged": true, "index": "elastalert_status"}`
+		}
+		return http.StatusCreated, `{"_id": "1", "result": "created"}`
+	})
+	defer f.server.Close()
+
+	e := newTestAlerter(&Config{WritebackIndex: "elastalert", BufferTime: "15m"}, client)
+	e.initWriteback(context.Background())
+	if mappings := f.find(http.MethodPut, "/elastalert_status"); len(mappings) != 1 {
+		t.Fatalf("expected the status index to be created, got %d", len(mappings))
+	}
+
+	e.runRule(context.Background(), &RuleBase{Name: "errors", Typ: "test_status", Index: "logs-*"})
+	docs := f.find(http.MethodPost, "/elastalert_status/_doc")
+	if len(docs) != 1 {
+		t.Fatalf("expected 1 status doc, got %d", len(docs))
+	}
+	var status RuleStatus
+	if err := json.Unmarshal(docs[0], &status); err != nil {
+		t.Fatal(err)
+	}
+	if status.RuleName != "errors" || status.Hits != 5 || status.Matches != 2 || status.EndTime.Sub(status.StartTime) != 15*time.Minute {
+		t.Errorf("unexpected status: %+v", status)
+	}
+	if !e.ruleEndTimes["errors"].Equal(status.EndTime) {
+		t.Errorf("unexpected end time: %s", e.ruleEndTimes["errors"])
+	}
+}
+
+func TestResumeFromWriteback(t *testing.T) {
+	endTime := time.Now().Add(-2 * time.Hour).UTC().Truncate(time.Second)
+	f, client := newFakeEs(t, func(method, path string, body []byte) (int, string) {
+		if !strings.HasSuffix(path, "/elastalert_status/_search") {
+			return http.StatusNotFound, `{}`
+		}
+		var hits []string
+		if strings.Contains(string(body), `"errors"`) {
+			hits = append(hits, `{"_id": "1", "_source": {"rule_name": "errors", "endtime": "`+endTime.Format(time.RFC3339)+`"}}`)
+		}
+		return http.StatusOK, `{"hits": {"total": {"value": 1}, "hits": [` + strings.Join(hits, ",") + `]}}`
+	})
+	defer f.server.Close()
+
+	e := newTestAlerter(&Config{WritebackIndex: "elastalert", BufferTime: "15m", OldQueryLimit: "1d"}, client)
+	ctx := context.Background()
+
+	w, err := e.getQueryWindow(ctx, &RuleBase{Name: "errors"})
+	if err != nil || !w.Start.Equal(endTime) {
+		t.Errorf("expected to resume from %s, got %+v err: %v", endTime, w, err)
+	}
+	w, err = e.getQueryWindow(ctx, &RuleBase{Name: "other"})
+	if err != nil || w.End.Sub(w.Start) != 15*time.Minute {
+		t.Errorf("expected to look back buffer_time, got %+v err: %v", w, err)
+	}
+
+	e.cfg.OldQueryLimit = "1h"
+	w, err = e.getQueryWindow(ctx, &RuleBase{Name: "errors"})
+	if err != nil || w.End.Sub(w.Start) != time.Hour {
+		t.Errorf("expected to look back old_query_limit, got %+v err: %v", w, err)
+	}
+}